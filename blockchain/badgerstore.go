@@ -0,0 +1,139 @@
+package blockchain
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/dgraph-io/badger"
+)
+
+// BadgerStore : Store in a badger database on disk
+type BadgerStore struct {
+	db *badger.DB
+}
+
+// OpenBadgerStore : open or create the badger database of the network of the options
+func OpenBadgerStore(opts Options) (*BadgerStore, error) {
+	if err := os.MkdirAll(opts.BlocksDir(), 0700); err != nil { // badger doesn't create the parent directories
+		return nil, err
+	}
+	db, err := badger.Open(opts.badgerOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	return &BadgerStore{db}, nil
+}
+
+// View : run fn in a read only badger transaction
+func (s *BadgerStore) View(fn func(txn Txn) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		return fn(newBadgerTxn(txn))
+	})
+}
+
+// Update : run fn in a badger transaction that is committed if fn returns nil
+func (s *BadgerStore) Update(fn func(txn Txn) error) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		return fn(newBadgerTxn(txn))
+	})
+	if err == badger.ErrConflict {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// Close : close the database
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
+
+// badgerTxn : Txn of a BadgerStore
+type badgerTxn struct {
+	keyBlocks
+	txn *badger.Txn
+}
+
+// newBadgerTxn : Txn of the badger transaction
+func newBadgerTxn(txn *badger.Txn) badgerTxn {
+	t := badgerTxn{txn: txn}
+	t.keyBlocks = keyBlocks{t}
+
+	return t
+}
+
+// Get : the value of the key in the transaction
+func (t badgerTxn) Get(key []byte) ([]byte, error) {
+	item, err := t.txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return item.ValueCopy(nil)
+}
+
+// Set : store the value of the key when the transaction is committed
+func (t badgerTxn) Set(key, value []byte) error {
+	return t.txn.Set(key, value)
+}
+
+// Delete : remove the key when the transaction is committed
+func (t badgerTxn) Delete(key []byte) error {
+	return t.txn.Delete(key)
+}
+
+// NewIterator : badger iterator limited to the prefix, the values are not prefetched when only the keys are read
+func (t badgerTxn) NewIterator(prefix []byte, keysOnly bool) Iterator {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = !keysOnly
+
+	return &badgerIterator{t.txn.NewIterator(opts), prefix}
+}
+
+// badgerIterator : Iterator of a badgerTxn
+type badgerIterator struct {
+	it     *badger.Iterator
+	prefix []byte
+}
+
+// Rewind : move to the first key of the prefix
+func (i *badgerIterator) Rewind() {
+	i.it.Seek(i.prefix)
+}
+
+// Seek : move to the first key of the prefix that is equal or greater than the key
+func (i *badgerIterator) Seek(key []byte) {
+	if bytes.Compare(key, i.prefix) < 0 {
+		key = i.prefix
+	}
+	i.it.Seek(key)
+}
+
+// Valid : the iterator is on a key of the prefix
+func (i *badgerIterator) Valid() bool {
+	return i.it.ValidForPrefix(i.prefix)
+}
+
+// Next : move to the next key
+func (i *badgerIterator) Next() {
+	i.it.Next()
+}
+
+// Key : a copy of the current key
+func (i *badgerIterator) Key() []byte {
+	return i.it.Item().KeyCopy(nil)
+}
+
+// Value : a copy of the value of the current key
+func (i *badgerIterator) Value() ([]byte, error) {
+	return i.it.Item().ValueCopy(nil)
+}
+
+// Close : release the badger iterator
+func (i *badgerIterator) Close() {
+	i.it.Close()
+}
@@ -1,10 +1,8 @@
 package blockchain
 
 import (
-	"bytes"
-	"crypto/sha256"
-	"encoding/gob"
-	"log"
+	"fmt"
+	"time"
 )
 
 /*
@@ -12,73 +10,201 @@ import (
 	different pairs
 */
 
+// BlockVersion : version of the block rules used to create new blocks, the version 2 separates the
+// leaves from the inner nodes of the Merkle tree and the version 3 hashes the header and the
+// transactions with the binary format
+const BlockVersion = 3
+
+/*
+	The header of a block is everything that is hashed by the proof of work, the transactions are
+	only represented inside of it by their Merkle root, so changing a transaction changes the root
+	and therefore the hash of the block.
+
+	Esp:
+
+	El header de un bloque es todo lo que se hashea en el proof of work, las transacciones solo se
+	representan dentro de el por su raíz de Merkle, asi que cambiar una transacción cambia la raíz y
+	por lo tanto el hash del bloque.
+*/
+
+// BlockHeader : the fields of a block that are hashed by the proof of work
+type BlockHeader struct {
+	Version    int
+	PrevHash   []byte //represent the last block hash, this allow us to link the block together
+	MerkleRoot []byte //root of the Merkle tree of the transactions
+	Timestamp  int64  //unix time in seconds when the block was mined
+	Difficulty int    //target bits, number of leading zero bits that the hash must have
+	Nonce      int
+	Height     int //number of blocks before this one, the genesis block has height 0
+}
+
 //Block : basic struct
 type Block struct {
+	BlockHeader
 	Hash         []byte //represent the hash of this block
 	Transactions []*Transaction
-	PrevHash     []byte //represent the last block hash, this allow us to link the block together
-	Nonce        int
 	// each block inside a blockchain references the last block that was created inside the blockchain
 }
 
-// HashTransactions : provide a unique representation of all of our transactions combined
+// HashTransactions : provide a unique representation of all of our transactions combined, the root of their Merkle tree
 func (b *Block) HashTransactions() []byte {
+	tree := b.MerkleTree()
+
+	return tree.RootNode.Data
+}
+
+// MerkleTree : Merkle tree with the IDs of the transactions of the block as leaves
+func (b *Block) MerkleTree() *MerkleTree {
 	var txHashes [][]byte
-	var txHash [32]byte
 
 	for _, tx := range b.Transactions { //iterate through each of the transactions inside of a block
 		txHashes = append(txHashes, tx.ID) // append each one to the two dimensional slice of bytes txHashes
 	}
 
-	txHash = sha256.Sum256(bytes.Join(txHashes, []byte{})) //concatenate all these bytes together and then hash them
+	return NewMerkleTree(txHashes, b.Version)
+}
+
+// checkTransactionIDs : check that the ID of each transaction is the hash of its content and that the Merkle
+// tree is not mutated, so the hash of the block commits to its transactions
+func (b *Block) checkTransactionIDs() error {
+	version := b.txVersion()
+	for _, tx := range b.Transactions {
+		if tx.Version != version {
+			return fmt.Errorf("%w: transaction %x of version %d in a block of version %d", ErrInvalidBlock, tx.ID, tx.Version, b.Version)
+		}
+		if err := tx.checkID(); err != nil {
+			return err
+		}
+	}
+	if b.MerkleTree().Mutated() {
+		return fmt.Errorf("%w: the Merkle tree has two equal siblings", ErrInvalidBlock)
+	}
 
-	return txHash[:]
+	return nil
 }
 
-// CreateBlock : Create a block
-func CreateBlock(txs []*Transaction, prevHash []byte) *Block {
-	block := &Block{[]byte{}, txs, prevHash, 0}
+// txVersion : version that every transaction of the block must have
+func (b *Block) txVersion() int {
+	if b.Version >= 3 {
+		return 2
+	}
+
+	return 1
+}
+
+// CreateBlock : Create and mine a block on top of the block with prevHash
+func CreateBlock(txs []*Transaction, prevHash []byte, height, difficulty int) (*Block, error) {
+	block := newBlock(txs, prevHash, height, difficulty)
 	pow := NewProof(block)
-	nonce, hash := pow.Run()
+	nonce, hash, err := pow.Run()
+	if err != nil {
+		return nil, err
+	}
 
 	block.Hash = hash[:]
 	block.Nonce = nonce
 
+	return block, nil
+}
+
+// newBlock : a block that is not mined yet
+func newBlock(txs []*Transaction, prevHash []byte, height, difficulty int) *Block {
+	header := BlockHeader{
+		Version:    BlockVersion,
+		PrevHash:   prevHash,
+		Timestamp:  time.Now().Unix(),
+		Difficulty: difficulty,
+		Height:     height,
+	}
+	block := &Block{header, []byte{}, txs}
+	block.MerkleRoot = block.HashTransactions()
+
 	return block
 }
 
 // Genesis : Genesis block
-func Genesis(coinbase *Transaction) *Block {
-	return CreateBlock([]*Transaction{coinbase}, []byte{}) //pase a array of transaction with only the coin base inside of it and an empty solice of bytes
+func Genesis(coinbase *Transaction, difficulty int) (*Block, error) {
+	return CreateBlock([]*Transaction{coinbase}, []byte{}, 0, difficulty) //pase a array of transaction with only the coin base inside of it and an empty solice of bytes
 }
 
 //BadgerDb Serialize - Deserialize
 
-func (b *Block) Serialize() []byte {
-	var res bytes.Buffer
-	encoder := gob.NewEncoder(&res)
-
-	err := encoder.Encode(b)
-
-	Handle(err)
+// Serialize : binary encoding of the block, described in encoding.go
+func (b *Block) Serialize() ([]byte, error) {
+	e := newEncoder()
+	e.writeHeader(b.BlockHeader)
+	e.writeBytes(b.Hash)
+	e.writeCount(len(b.Transactions))
+	for _, tx := range b.Transactions {
+		e.writeTx(tx)
+	}
 
-	return res.Bytes()
+	return e.buffer.Bytes(), nil
 }
 
-func Deserialize(data []byte) *Block {
-	var block Block
+/*
+	Blocks were encoded with gob before the binary format. The first blocks didn't have a header,
+	their PrevHash and Nonce were fields of the block, and since the header exists gob writes it
+	as a field named BlockHeader. legacyBlock has the fields of both layouts, gob fills the ones
+	that the data has and leaves the others empty.
 
-	decoder := gob.NewDecoder(bytes.NewReader(data))
+	Esp:
 
-	err := decoder.Decode(&block)
+	Los bloques se codificaban con gob antes del formato binario. Los primeros bloques no tenían
+	header, su PrevHash y Nonce eran campos del bloque, y desde que existe el header gob lo escribe
+	como un campo llamado BlockHeader. legacyBlock tiene los campos de ambos formatos, gob llena los
+	que los datos tienen y deja los otros vacíos.
+*/
 
-	Handle(err)
+// legacyBlock : a block encoded with gob by older versions
+type legacyBlock struct {
+	BlockHeader  *BlockHeader // nil in the blocks without header
+	Hash         []byte
+	Transactions []*Transaction
+	PrevHash     []byte // fields of the blocks without header
+	Nonce        int
+}
 
-	return &block
+// block : the block with the fields of its layout
+func (legacy legacyBlock) block() *Block {
+	block := &Block{Hash: legacy.Hash, Transactions: legacy.Transactions}
+	if legacy.BlockHeader != nil {
+		block.BlockHeader = *legacy.BlockHeader
+	} else {
+		block.PrevHash = legacy.PrevHash
+		block.Nonce = legacy.Nonce
+	}
+	for _, tx := range block.Transactions {
+		tx.Version = 1 // gob transactions were created before the versions
+	}
+
+	return block
 }
 
-func Handle(err error) {
-	if err != nil {
-		log.Panic(err)
+// Deserialize : decode a block encoded with Serialize or with gob by older versions
+func Deserialize(data []byte) (*Block, error) {
+	var block Block
+
+	if isLegacy(data) {
+		var legacy legacyBlock
+		if err := decodeLegacy(data, &legacy); err != nil {
+			return nil, err
+		}
+		return legacy.block(), nil
+	}
+
+	d := newDecoder(data)
+	block.BlockHeader = d.readHeader()
+	block.Hash = d.readBytes()
+	if n := d.readCount(); n > 0 {
+		block.Transactions = make([]*Transaction, 0, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			block.Transactions = append(block.Transactions, d.readTx())
+		}
 	}
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+
+	return &block, nil
 }
@@ -1,169 +1,578 @@
 package blockchain
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
-	"runtime"
+	"path/filepath"
+	"sort"
+	"time"
 
-	"github.com/dgraph-io/badger"
+	"golang.org/x/crypto/ripemd160"
 )
 
 //BadgerDB v1.5.4
-// Badger is a key - value database written in pure Go
-const (
-	dbPath      = "./tmp/blocks"
-	dbFile      = "./tmp/blocks/MANIFEST"
-	genesisData = "First Transaction from Genesis"
-)
+// Badger is a key - value database written in pure Go, the database of each network is stored in Options.BlocksDir
+// unless Options.Store sets another Store
 
 // BlockChain : BlockChain struct
 type BlockChain struct {
 	LastHash []byte
-	Database *badger.DB
+	Params   ChainParams
+	Miner    MinerOptions // how the blocks added with AddBlock are mined
+	Events   *EventBus    // tells when blocks join or leave the main chain and when transactions reach the mempool
+
+	store        Store
+	orphans      map[string][]*Block // blocks whose previous block is unknown, by the hash of the previous block
+	orphanCount  int
+	txIndex      bool // the transactions of the main chain are in the tx index
+	addressIndex bool // the transactions of the main chain are in the address index
 }
 
 type BlockChainIterator struct {
 	CurrentHash []byte
-	Database    *badger.DB
+	store       Store
 }
 
-//DBexists : allow us to determinate if the badgerDB exists
-func DBexists() bool {
-	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
+//DBexists : allow us to determinate if the badgerDB exists in the directory
+func DBexists(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, "MANIFEST")); os.IsNotExist(err) {
 		return false
 	}
 	return true
 }
 
 //InitBlockChain : initialize the DB and the blockchain as well
-func InitBlockChain(address string) *BlockChain {
-	var lastHash []byte
+func InitBlockChain(address string, opts Options) (*BlockChain, error) {
+	params, err := opts.Params()
+	if err != nil {
+		return nil, err
+	}
 
-	if DBexists() {
-		fmt.Println("Blockchain already exists")
-		runtime.Goexit()
+	if opts.Store == nil && DBexists(opts.BlocksDir()) {
+		return nil, ErrChainExists
 	}
 
-	opts := badger.DefaultOptions
-	opts.Dir = dbPath
-	opts.ValueDir = dbPath
+	cbtx, err := CoinbaseTx(address, params.GenesisData, params.Subsidy(0))
+	if err != nil {
+		return nil, err
+	}
+	genesis, err := Genesis(cbtx, params.InitialDifficulty)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := badger.Open(opts)
-	Handle(err)
+	return initChain(genesis, params, opts)
+}
 
-	err = db.Update(func(txn *badger.Txn) error {
-		cbtx := CoinbaseTx(address, genesisData)
-		genesis := Genesis(cbtx)
-		fmt.Println("Genesis created")
-		err = txn.Set(genesis.Hash, genesis.Serialize())
-		Handle(err)
-		err = txn.Set(genesis.Hash, genesis.Serialize())
-		Handle(err)
-		err = txn.Set([]byte("lh"), genesis.Hash)
+// initChain : store the genesis block as the whole chain, the store of the options must not have a chain
+func initChain(genesis *Block, params ChainParams, opts Options) (*BlockChain, error) {
+	store, err := opts.openStore()
+	if err != nil {
+		return nil, err
+	}
 
-		lastHash = genesis.Hash
+	blockchain := BlockChain{LastHash: genesis.Hash, Params: params, Events: NewEventBus(), store: store, txIndex: opts.TxIndex, addressIndex: opts.AddressIndex}
 
-		return err
-	})
+	err = store.Update(func(txn Txn) error {
+		if _, err := txn.ReadTip(); err != ErrKeyNotFound {
+			if err == nil {
+				return ErrChainExists
+			}
+			return err
+		}
+		if err := txn.StoreBlock(genesis, blockWork(genesis.Difficulty)); err != nil {
+			return err
+		}
+		if err := txn.StoreTip(genesis.Hash); err != nil {
+			return err
+		}
+		if err := setSchema(txn); err != nil {
+			return err
+		}
 
-	Handle(err)
+		UTXOSet := UTXOSet{Blockchain: &blockchain}
+		spent, err := UTXOSet.update(txn, genesis) // the reward of the genesis block is the first unspent output
+		if err != nil {
+			return err
+		}
+		if err := storeUndo(txn, genesis.Hash, spent); err != nil {
+			return err
+		}
+
+		if err := blockchain.connectIndex(txn, genesis, spent); err != nil {
+			return err
+		}
+		if opts.TxIndex {
+			if err := txn.Set(txIndexKey, []byte{1}); err != nil {
+				return err
+			}
+		}
+		if opts.AddressIndex {
+			return txn.Set(addrIndexKey, []byte{1})
+		}
+		return nil
+	})
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
 
-	blockchain := BlockChain{lastHash, db}
-	return &blockchain
+	return &blockchain, nil
 }
 
-func ContinueBlockChain(address string) *BlockChain {
-	if DBexists() == false {
-		fmt.Println("No existing blockchain found, create one!!")
-		runtime.Goexit()
+// ContinueBlockChain : open the existing blockchain
+func ContinueBlockChain(opts Options) (*BlockChain, error) {
+	params, err := opts.Params()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Store == nil && DBexists(opts.BlocksDir()) == false {
+		return nil, ErrNoChain
 	}
 
 	var lastHash []byte
+	var txIndex, addressIndex bool
 
-	opts := badger.DefaultOptions
-	opts.Dir = dbPath
-	opts.ValueDir = dbPath
+	store, err := opts.openStore()
+	if err != nil {
+		return nil, err
+	}
+
+	err = store.View(func(txn Txn) error {
+		if err := checkSchema(txn); err != nil {
+			return err
+		}
 
-	db, err := badger.Open(opts)
-	Handle(err)
+		var err error
+		lastHash, err = txn.ReadTip()
+		if err == ErrKeyNotFound {
+			return ErrNoChain
+		}
+		if err != nil {
+			return err
+		}
 
-	err = db.Update(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte("lh"))
-		Handle(err)
-		lastHash, err = item.Value()
+		if txIndex, err = hasKey(txn, txIndexKey); err != nil {
+			return err
+		}
+		addressIndex, err = hasKey(txn, addrIndexKey)
 
 		return err
 	})
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
 
-	Handle(err)
+	chain := BlockChain{LastHash: lastHash, Params: params, Events: NewEventBus(), store: store, txIndex: txIndex, addressIndex: addressIndex}
+
+	if err := chain.indexChain(opts.TxIndex, opts.AddressIndex); err != nil { // chains stored before the indexes existed, or without the optional ones
+		store.Close()
+		return nil, err
+	}
+
+	return &chain, nil
+}
 
-	chain := BlockChain{lastHash, db}
+// Close : stop the events of the chain and close its store
+func (chain *BlockChain) Close() error {
+	chain.Events.Close()
 
-	return &chain
+	return chain.store.Close()
 }
 
-//AddBlock : add a new block to the chain
-func (chain *BlockChain) AddBlock(transactions []*Transaction) {
+//AddBlock : mine a new block with the transactions and add it to the chain
+func (chain *BlockChain) AddBlock(transactions []*Transaction) (*Block, error) {
+	return chain.MineBlock(context.Background(), transactions)
+}
+
+// MineBlock : mine a block with the transactions on top of the last block, cancelling the context aborts the mining
+func (chain *BlockChain) MineBlock(ctx context.Context, transactions []*Transaction) (*Block, error) {
+	newBlock, err := chain.NewBlockTemplate(transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, hash, err := NewProof(newBlock).Mine(ctx, chain.Miner)
+	if err != nil {
+		return nil, err
+	}
+	newBlock.Nonce = nonce
+	newBlock.Hash = hash
+
+	change, err := chain.AcceptBlock(newBlock)
+	if err != nil {
+		return nil, err
+	}
+	if change == nil { // another block was added while we were mining, ours is kept on a side branch
+		return nil, ErrStaleBlock
+	}
+
+	return newBlock, nil
+}
+
+// NewBlockTemplate : block with the transactions on top of the last block, ready to be mined
+func (chain *BlockChain) NewBlockTemplate(transactions []*Transaction) (*Block, error) {
 	var lastHash []byte
 
-	err := chain.Database.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte("lh"))
-		Handle(err)
-		lastHash, err = item.Value()
+	err := chain.store.View(func(txn Txn) error {
+		var err error
+		lastHash, err = txn.ReadTip()
 
 		return err
 	})
-	Handle(err)
+	if err != nil {
+		return nil, err
+	}
+
+	lastBlock, err := chain.GetBlock(lastHash)
+	if err != nil {
+		return nil, err
+	}
+
+	difficulty, err := chain.NextDifficulty(&lastBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	block := newBlock(transactions, lastHash, lastBlock.Height+1, difficulty)
+	if err := chain.validateTransactions(block); err != nil { // a block can only be mined with transactions that follow the rules
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// NewCoinbase : coinbase for the next block with the transactions, it pays the subsidy and the fees of the transactions to the address
+func (chain *BlockChain) NewCoinbase(to string, transactions []*Transaction) (*Transaction, error) {
+	height, err := chain.GetBestHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	fees := 0
+	for _, tx := range transactions {
+		fee, err := chain.Fee(tx)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if fees, ok = addValue(fees, fee); !ok {
+			return nil, fmt.Errorf("%w: the fees of the transactions overflow", ErrInvalidTx)
+		}
+	}
+
+	return CoinbaseTx(to, "", chain.Params.Subsidy(height+1)+fees)
+}
+
+// validateTransactions : check the transactions of a block against the branch that ends in its previous block,
+// and that its coinbase doesn't claim more than the subsidy and the fees
+func (chain *BlockChain) validateTransactions(block *Block) error {
+	fees := 0
+	var coinbase *Transaction
+
+	for i, tx := range block.Transactions {
+		if err := tx.checkID(); err != nil { // the ID must be the hash of the content, otherwise the Merkle root proves nothing
+			return err
+		}
+
+		if tx.IsCoinbase() {
+			if i != 0 { // only the first transaction of a block can create new coins
+				return fmt.Errorf("%w: coinbase %x at position %d", ErrInvalidBlock, tx.ID, i)
+			}
+			coinbase = tx
+			continue
+		}
+
+		valid, err := chain.verifyTransaction(tx, block.PrevHash)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return fmt.Errorf("%w: %x", ErrInvalidTx, tx.ID)
+		}
+
+		fee, err := chain.fee(tx, block.PrevHash)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if fees, ok = addValue(fees, fee); !ok {
+			return fmt.Errorf("%w: the fees of the block overflow", ErrInvalidBlock)
+		}
+	}
+
+	if coinbase != nil {
+		return checkCoinbase(coinbase, chain.Params.Subsidy(block.Height), fees)
+	}
+
+	return nil
+}
+
+// checkCoinbase : the outputs of the coinbase must be positive and add up to at most the subsidy plus the fees
+func checkCoinbase(coinbase *Transaction, subsidy, fees int) error {
+	allowed, ok := addValue(subsidy, fees)
+	if !ok {
+		return fmt.Errorf("%w: the subsidy %d and the fees %d overflow", ErrInvalidBlock, subsidy, fees)
+	}
+
+	claimed := 0
+	for _, out := range coinbase.Outputs {
+		if out.Value <= 0 {
+			return fmt.Errorf("%w: coinbase %x has an output with value %d", ErrInvalidBlock, coinbase.ID, out.Value)
+		}
+		if len(out.PubKeyHash) != ripemd160.Size {
+			return fmt.Errorf("%w: coinbase %x has an output locked to %d bytes instead of a public key hash", ErrInvalidBlock, coinbase.ID, len(out.PubKeyHash))
+		}
+		if claimed, ok = addValue(claimed, out.Value); !ok {
+			return fmt.Errorf("%w: the outputs of coinbase %x overflow", ErrInvalidBlock, coinbase.ID)
+		}
+	}
+	if claimed > allowed {
+		return fmt.Errorf("%w: coinbase claims %d, the subsidy and the fees are %d", ErrInvalidBlock, claimed, allowed)
+	}
+
+	return nil
+}
+
+// HasBlock : check if the block with the hash is stored in the database
+func (chain *BlockChain) HasBlock(blockHash []byte) (bool, error) {
+	_, err := chain.GetBlock(blockHash)
+	if errors.Is(err, ErrBlockNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// GetBestHeight : height of the last block of the chain
+func (chain *BlockChain) GetBestHeight() (int, error) {
+	lastBlock, err := chain.GetBlock(chain.LastHash)
+	if err != nil {
+		return 0, err
+	}
+
+	return lastBlock.Height, nil
+}
+
+// GenesisHash : hash of the first block of the chain, two nodes can only share blocks if they have the same genesis
+func (chain *BlockChain) GenesisHash() ([]byte, error) {
+	iter := chain.Iterator()
+
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if len(block.PrevHash) == 0 {
+			return block.Hash, nil
+		}
+	}
+}
+
+// BlockHashesAfter : hashes of the blocks that follow the block with the locator hash, from the oldest to the newest.
+// If the locator is not part of our chain the hashes start at the genesis block, at most limit hashes are returned
+func (chain *BlockChain) BlockHashesAfter(locator []byte, limit int) ([][]byte, error) {
+	var hashes [][]byte
+
+	iter := chain.Iterator()
+
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(block.Hash, locator) {
+			break
+		}
+		hashes = append(hashes, block.Hash)
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 { // the iterator walks backwards
+		hashes[i], hashes[j] = hashes[j], hashes[i]
+	}
+
+	if limit > 0 && len(hashes) > limit {
+		hashes = hashes[:limit]
+	}
+
+	return hashes, nil
+}
 
-	newBlock := CreateBlock(transactions, lastHash)
+/*
+	Timestamps can't be trusted, every miner sets the time of its own blocks, so a block is only
+	accepted if its timestamp is not earlier than the median of the timestamps of the last blocks,
+	and not too far in the future. The median can only move forward, a single miner with a wrong
+	clock can't move it back.
 
-	err = chain.Database.Update(func(txn *badger.Txn) error {
-		err := txn.Set(newBlock.Hash, newBlock.Serialize())
-		Handle(err)
-		err = txn.Set([]byte("lh"), newBlock.Hash)
+	Esp:
 
-		chain.LastHash = newBlock.Hash
+	No se puede confiar en los timestamps, cada minero establece la hora de sus propios bloques, por
+	eso un bloque solo se acepta si su timestamp no es anterior a la mediana de los timestamps de los
+	últimos bloques, y no esta demasiado en el futuro. La mediana solo puede avanzar, un solo minero
+	con un reloj equivocado no la puede hacer retroceder.
+*/
 
+const (
+	maxFutureBlockTime = 2 * 60 * 60 // seconds that a timestamp can be ahead of our clock
+	medianTimeBlocks   = 11          // number of previous blocks used to compute the median time
+)
+
+// ValidateHeader : check the header of a block that is going to be added on top of its previous block
+func (chain *BlockChain) ValidateHeader(block *Block) error {
+	if block.Version < 1 || block.Version > BlockVersion {
+		return fmt.Errorf("%w: unknown block version %d", ErrInvalidBlock, block.Version)
+	}
+	if block.Difficulty < chain.Params.MinDifficulty || block.Difficulty > chain.Params.MaxDifficulty { // before the proof of work uses it
+		return fmt.Errorf("%w: block difficulty %d outside of %d..%d", ErrInvalidBlock, block.Difficulty, chain.Params.MinDifficulty, chain.Params.MaxDifficulty)
+	}
+
+	if !NewProof(block).Validate() {
+		return fmt.Errorf("%w: invalid proof of work", ErrInvalidBlock)
+	}
+	if err := block.checkTransactionIDs(); err != nil { // side branches are stored before their transactions are checked
 		return err
-	})
-	Handle(err)
+	}
+
+	if block.Timestamp > time.Now().Unix()+maxFutureBlockTime {
+		return fmt.Errorf("%w: block timestamp %d is too far in the future", ErrInvalidBlock, block.Timestamp)
+	}
+
+	if len(block.PrevHash) == 0 { // the genesis block has nothing to be compared with
+		if block.Height != 0 {
+			return fmt.Errorf("%w: genesis block with height %d", ErrInvalidBlock, block.Height)
+		}
+		if block.Difficulty != chain.Params.InitialDifficulty {
+			return fmt.Errorf("%w: block difficulty %d, expected %d", ErrInvalidBlock, block.Difficulty, chain.Params.InitialDifficulty)
+		}
+		return nil
+	}
+
+	prevBlock, err := chain.GetBlock(block.PrevHash)
+	if err != nil {
+		return err
+	}
+
+	if block.Version < prevBlock.Version { // the rules of a chain never go back to an older version
+		return fmt.Errorf("%w: block version %d after a block of version %d", ErrInvalidBlock, block.Version, prevBlock.Version)
+	}
+	if block.Height != prevBlock.Height+1 {
+		return fmt.Errorf("%w: block height %d, expected %d", ErrInvalidBlock, block.Height, prevBlock.Height+1)
+	}
+
+	difficulty, err := chain.NextDifficulty(&prevBlock)
+	if err != nil {
+		return err
+	}
+	if block.Difficulty != difficulty { // the proof of work must be done with the difficulty that the chain expects at this height
+		return fmt.Errorf("%w: block difficulty %d, expected %d", ErrInvalidBlock, block.Difficulty, difficulty)
+	}
+
+	median, err := chain.MedianTimePast(block.PrevHash)
+	if err != nil {
+		return err
+	}
+	if block.Timestamp < median {
+		return fmt.Errorf("%w: block timestamp %d is earlier than the median time of the previous blocks %d", ErrInvalidBlock, block.Timestamp, median)
+	}
+
+	return nil
 }
 
+// NextDifficulty : difficulty that the chain rules expect for the block that goes on top of prevBlock
+func (chain *BlockChain) NextDifficulty(prevBlock *Block) (int, error) {
+	params := chain.Params
+	height := prevBlock.Height + 1
+
+	if height%params.RetargetInterval != 0 { // between retargets every block keeps the difficulty of its parent
+		return prevBlock.Difficulty, nil
+	}
+
+	first := prevBlock // walk back to the first block of the interval
+	for first.Height > height-params.RetargetInterval && len(first.PrevHash) != 0 {
+		block, err := chain.GetBlock(first.PrevHash)
+		if err != nil {
+			return 0, err
+		}
+		first = &block
+	}
+
+	actual := prevBlock.Timestamp - first.Timestamp
+	expected := int64(prevBlock.Height-first.Height) * params.TargetBlockTime
+
+	return params.retarget(prevBlock.Difficulty, actual, expected), nil
+}
+
+// MedianTimePast : median of the timestamps of the last blocks ending in the block with the hash
+func (chain *BlockChain) MedianTimePast(blockHash []byte) (int64, error) {
+	var timestamps []int64
+
+	for len(timestamps) < medianTimeBlocks {
+		block, err := chain.GetBlock(blockHash)
+		if err != nil {
+			return 0, err
+		}
+		timestamps = append(timestamps, block.Timestamp)
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+		blockHash = block.PrevHash
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	return timestamps[len(timestamps)/2], nil
+}
+
+// Iterator : walks the chain from the last block back to the genesis block
 func (chain *BlockChain) Iterator() *BlockChainIterator {
-	iter := &BlockChainIterator{chain.LastHash, chain.Database}
+	iter := &BlockChainIterator{chain.LastHash, chain.store}
 
 	return iter
 }
 
-func (iter *BlockChainIterator) Next() *Block {
+// Next : the current block of the iterator, then the iterator moves to its previous block
+func (iter *BlockChainIterator) Next() (*Block, error) {
 	var block *Block
 
-	err := iter.Database.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(iter.CurrentHash)
-		Handle(err)
-		encodedBlock, err := item.Value()
-		block = Deserialize(encodedBlock)
+	err := iter.store.View(func(txn Txn) error {
+		var err error
+		block, err = txn.ReadBlock(iter.CurrentHash)
 
 		return err
 	})
-	Handle(err)
+	if err != nil {
+		return nil, err
+	}
 
 	iter.CurrentHash = block.PrevHash
 
-	return block
+	return block, nil
 }
 
-//FindUnspentTransactions : Find all unspent transactions witch are assigned to an address
-func (chain *BlockChain) FindUnspentTransactions(address string) []Transaction {
-	var unspentTxs []Transaction //array of transactions
+// FindUTXO : walk the whole chain collecting every unspent output, used to rebuild the UTXO set
+func (chain *BlockChain) FindUTXO() (map[string]TxOutputs, error) {
+	UTXO := make(map[string]TxOutputs)
+	spentTXOs := make(map[string][]int) // where keys are transaction IDs and values are the indexes of their spent outputs
 
-	spentTXOs := make(map[string][]int) // where keys are strings and values are slice of ints
-
-	iter := chain.Iterator() // iterate through the blockchain in the data base
+	iter := chain.Iterator() // iterate through the blockchain in the data base, from the last block to the genesis
 
 	for {
-		block := iter.Next()
+		block, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
 
 		for _, tx := range block.Transactions { // iterate through each of the transactions inside of a block
 			txID := hex.EncodeToString(tx.ID) // take the transaction IDs of each of the transactions and encoded into hexadecimal
@@ -177,16 +586,17 @@ func (chain *BlockChain) FindUnspentTransactions(address string) []Transaction {
 						}
 					}
 				}
-				if out.CanBeUnlocked(address) { // determinate if the output can be unlocked by the address that we are searching for
-					unspentTxs = append(unspentTxs, *tx) //take each of the transactions that can be unlock by these address an put in into the unspent transactions
+				outs, ok := UTXO[txID]
+				if !ok {
+					outs = TxOutputs{make(map[int]TxOutput)}
+					UTXO[txID] = outs
 				}
+				outs.Outputs[outIdx] = out // the output is not referenced by any later input so it is unspent
 			}
 			if tx.IsCoinbase() == false { // check if the transaction is a coinbase transaction or not
-				for _, in := range tx.Inputs { // if not iterate through the transaction inputs, is a way to find other outputs that are referenced by inputs
-					if in.CanUnlock(address) { // if we find other outputs check if we can unlock that outputs with the address
-						inTxID := hex.EncodeToString(in.ID)
-						spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Out) // if we can put it insede of the map
-					}
+				for _, in := range tx.Inputs { // if not iterate through the transaction inputs, every output referenced by an input is spent
+					inTxID := hex.EncodeToString(in.ID)
+					spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Out)
 				}
 			}
 		}
@@ -196,48 +606,162 @@ func (chain *BlockChain) FindUnspentTransactions(address string) []Transaction {
 		}
 	}
 
-	return unspentTxs //return the unspent transactions array which would have all unspent transaction which are assigned to the user account which we push through this function
+	return UTXO, nil
 }
 
-// FindUTXO : find all the unspent transactions outputs
-func (chain *BlockChain) FindUTXO(address string) []TxOutput {
-	var UTXOs []TxOutput
-	unspentTransactions := chain.FindUnspentTransactions(address)
+// GetBlock : the block stored with the hash
+func (chain *BlockChain) GetBlock(blockHash []byte) (Block, error) {
+	var block Block
 
-	for _, tx := range unspentTransactions { // iterate through the unspent transactions
-		for _, out := range tx.Outputs { // iterate through the outputs in the transactions
-			if out.CanBeUnlocked(address) { // check if the outputs can be unlocked by the address
-				UTXOs = append(UTXOs, out) // if they can we add them to the unspent transactions output var
-			}
+	err := chain.store.View(func(txn Txn) error {
+		decoded, err := txn.ReadBlock(blockHash)
+		if err != nil {
+			return err
 		}
+		block = *decoded
+
+		return nil
+	})
+
+	return block, err
+}
+
+// ProveTransaction : Merkle inclusion proof of the transaction inside of the block
+func (chain *BlockChain) ProveTransaction(txID, blockHash []byte) (*MerkleProof, error) {
+	block, err := chain.GetBlock(blockHash)
+	if err != nil {
+		return nil, err
 	}
 
-	return UTXOs // the return the unspent transactions
+	return block.MerkleProof(txID)
 }
 
-// FindSpendableOutputs : find all the unspent outputs and then ensure they have enough tokens inside of them
-func (chain *BlockChain) FindSpendableOutputs(address string, amount int) (int, map[string][]int) {
-	unspentOuts := make(map[string][]int)                // unspent outputs
-	unspentTxs := chain.FindUnspentTransactions(address) // unspent transactions
-	accumulated := 0
+// FindTransaction : the transaction of the main chain with the ID, found with the tx index when the chain has one
+func (chain *BlockChain) FindTransaction(ID []byte) (Transaction, error) {
+	return chain.findTransaction(ID, chain.LastHash)
+}
 
-Work:
-	for _, tx := range unspentTxs { // iterate through the unspent transactions
-		txID := hex.EncodeToString(tx.ID) // Encode transaction id into hexadecimal and assign it to txID
+// FindTransactionBlock : the transaction with the ID and the block of the main chain that contains it
+func (chain *BlockChain) FindTransactionBlock(ID []byte) (*Transaction, *Block, error) {
+	if chain.txIndex {
+		return chain.indexedTransaction(ID)
+	}
 
-		for outIdx, out := range tx.Outputs { // iterate through the outputs inside of the unspent transactions
-			if out.CanBeUnlocked(address) && accumulated < amount { // check if the output can be unlocked by the address and if the accumulated is less than the amount that we want to send
-				accumulated += out.Value                              // increment the accumulated value by the output value
-				unspentOuts[txID] = append(unspentOuts[txID], outIdx) // add the output index to the output map
+	iter := chain.Iterator()
 
-				if accumulated >= amount {
-					break Work
-				}
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return nil, nil, err
+		}
 
+		for _, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, ID) {
+				return tx, block, nil
 			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			return nil, nil, fmt.Errorf("%w: %x", ErrTxNotFound, ID)
+		}
+	}
+}
+
+// findTransaction : walk the branch that ends in the block with the tip hash looking for the transaction with the ID,
+// the tx index is used when the branch is the main chain
+func (chain *BlockChain) findTransaction(ID, tip []byte) (Transaction, error) {
+	if chain.txIndex && bytes.Equal(tip, chain.LastHash) {
+		tx, _, err := chain.indexedTransaction(ID)
+		if err != nil {
+			return Transaction{}, err
+		}
+		return *tx, nil
+	}
+
+	iter := &BlockChainIterator{tip, chain.store}
+
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return Transaction{}, err
+		}
+
+		for _, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, ID) {
+				return *tx, nil
+			}
+		}
 
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return Transaction{}, fmt.Errorf("%w: %x", ErrTxNotFound, ID)
+}
+
+// prevTransactions : the transactions referenced by the inputs of tx, looked up in the branch that ends in the tip
+func (chain *BlockChain) prevTransactions(tx *Transaction, tip []byte) (map[string]Transaction, error) {
+	prevTXs := make(map[string]Transaction)
+
+	for _, in := range tx.Inputs {
+		prevTX, err := chain.findTransaction(in.ID, tip)
+		if err != nil {
+			return nil, err
 		}
+		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+	}
+
+	return prevTXs, nil
+}
+
+// Fee : the value of the outputs spent by the transaction that is not sent to its outputs, the miner gets it
+func (chain *BlockChain) Fee(tx *Transaction) (int, error) {
+	return chain.fee(tx, chain.LastHash)
+}
+
+// fee : same as Fee but the spent outputs are looked up in the branch that ends in the tip
+func (chain *BlockChain) fee(tx *Transaction, tip []byte) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	prevTXs, err := chain.prevTransactions(tx, tip)
+	if err != nil {
+		return 0, err
+	}
+
+	return tx.Fee(prevTXs)
+}
+
+// SignTransaction : sign the inputs of the transaction with the private key
+func (chain *BlockChain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) error {
+	prevTXs, err := chain.prevTransactions(tx, chain.LastHash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Sign(privKey, prevTXs)
+}
+
+// VerifyTransaction : check the signatures of the transaction against the outputs it spends
+func (chain *BlockChain) VerifyTransaction(tx *Transaction) (bool, error) {
+	return chain.verifyTransaction(tx, chain.LastHash)
+}
+
+// verifyTransaction : same as VerifyTransaction but the spent outputs are looked up in the branch that ends in the tip
+func (chain *BlockChain) verifyTransaction(tx *Transaction, tip []byte) (bool, error) {
+	if tx.IsCoinbase() {
+		return true, nil
+	}
+
+	prevTXs, err := chain.prevTransactions(tx, tip)
+	if errors.Is(err, ErrTxNotFound) { // an input that references an unknown transaction can't be valid
+		return false, nil
+	}
+	if err != nil {
+		return false, err
 	}
 
-	return accumulated, unspentOuts
+	return tx.Verify(prevTXs), nil
 }
@@ -0,0 +1,124 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+	"golang.org/x/crypto/ripemd160"
+)
+
+func TestCheckCoinbase(t *testing.T) {
+	coinbase := func(values ...int) *Transaction {
+		tx := &Transaction{Inputs: []TxInput{{[]byte{}, -1, nil, []byte("data")}}}
+		for _, value := range values {
+			tx.Outputs = append(tx.Outputs, TxOutput{value, make([]byte, ripemd160.Size)})
+		}
+		return tx
+	}
+
+	for _, test := range []struct {
+		name    string
+		tx      *Transaction
+		fees    int
+		invalid bool
+	}{
+		{"subsidy and fees", coinbase(60, 50), 10, false},
+		{"no outputs", coinbase(), 0, false},
+		{"more than allowed", coinbase(111), 10, true},
+		{"zero output", coinbase(100, 0), 0, true},
+		{"negative output", coinbase(200, -100), 0, true},
+		{"outputs overflow", coinbase(maxValue, maxValue, 2), 0, true},
+		{"fees overflow", coinbase(1), maxValue, true},
+		{"long public key hash", &Transaction{Outputs: []TxOutput{{10, make([]byte, ripemd160.Size+12)}}}, 0, true},
+	} {
+		err := checkCoinbase(test.tx, 100, test.fees)
+		if test.invalid != errors.Is(err, ErrInvalidBlock) {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
+
+func TestFeeRejectsOverflow(t *testing.T) {
+	prev := Transaction{ID: []byte("prev"), Outputs: []TxOutput{{maxValue, nil}, {1, nil}}}
+	prevTXs := map[string]Transaction{"70726576": prev}
+
+	hash := make([]byte, ripemd160.Size)
+	tx := &Transaction{Inputs: []TxInput{{prev.ID, 0, nil, nil}, {prev.ID, 1, nil, nil}}, Outputs: []TxOutput{{1, hash}}}
+	if _, err := tx.Fee(prevTXs); !errors.Is(err, ErrInvalidTx) {
+		t.Errorf("inputs that overflow: %v", err)
+	}
+
+	tx = &Transaction{Inputs: []TxInput{{prev.ID, 0, nil, nil}}, Outputs: []TxOutput{{maxValue, hash}, {maxValue, hash}}}
+	if _, err := tx.Fee(prevTXs); !errors.Is(err, ErrInvalidTx) {
+		t.Errorf("outputs that overflow: %v", err)
+	}
+}
+
+func TestAcceptBlockRejectsDifficultyOutOfRange(t *testing.T) {
+	w, err := wallet.MakeWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := InitBlockChain(string(w.Address()), Options{Network: "test", Store: NewMemoryStore()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	cbTx, err := chain.NewCoinbase(string(w.Address()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, difficulty := range []int{300, -1} { // a target of 2^(256-300) can't be built
+		block := newBlock([]*Transaction{cbTx}, chain.LastHash, 1, difficulty)
+		block.Hash = block.BlockHeader.Hash()
+		if _, err := chain.AcceptBlock(block); !errors.Is(err, ErrInvalidBlock) {
+			t.Errorf("difficulty %d: %v", difficulty, err)
+		}
+	}
+
+	for _, difficulty := range []int{-1, hashBits + 1} {
+		if meetsTarget(make([]byte, 32), difficulty) {
+			t.Errorf("a hash meets the target of the difficulty %d", difficulty)
+		}
+	}
+}
+
+func TestAddressIndexKeepsOtherHashesOut(t *testing.T) {
+	w, err := wallet.MakeWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := InitBlockChain(string(w.Address()), Options{Network: "test", AddressIndex: true, Store: NewMemoryStore()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	victim := wallet.PublicKeyHash(w.PublicKey)
+
+	cbTx, err := chain.NewCoinbase(string(w.Address()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cbTx.Outputs[0].Value--
+	cbTx.Outputs = append(cbTx.Outputs, TxOutput{1, append(append([]byte{}, victim...), make([]byte, addressKeyLength)...)})
+	if err := cbTx.SetID(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.AddBlock([]*Transaction{cbTx}); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("a block with an output locked to a longer hash: %v", err)
+	}
+
+	err = chain.store.Update(func(txn Txn) error { // an entry of such an output indexed before it was invalid
+		long := append(append([]byte{}, victim...), make([]byte, addressKeyLength)...)
+		return txn.Set(addressKey(long, 1, 0), encodeAddressEntry(AddressTx{TxID: cbTx.ID, Received: 1}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := chain.AddressHistory(victim, 0, 0)
+	if err != nil || len(history) != 1 {
+		t.Errorf("%d entries, %v, expected the genesis coinbase", len(history), err)
+	}
+}
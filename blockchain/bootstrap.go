@@ -0,0 +1,299 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+/*
+	A bootstrap file moves a chain between machines without copying the badger directory. It has
+	the blocks of the main chain from the genesis block to the tip, in height order, each one with
+	its length and a checksum so a file that was cut or damaged is detected before its blocks are
+	decoded:
+
+		header  the bytes "BCBOOT", byte bootstrapVersion, uint64 number of blocks
+		record  uint32 length of the block, 4 bytes of the double sha256 of the block, the block
+		        encoded as described in encoding.go
+
+	The numbers are big endian. The import doesn't trust the file: every block is checked like a
+	block received from a peer, proof of work, previous block, height, difficulty, timestamp and
+	transactions, and the import stops at the first block that breaks the rules. The blocks that
+	the database already has are skipped, so a file can be imported again or on top of a chain
+	that has some of its blocks, and a network without a chain is created with the genesis block
+	of the file.
+
+	Esp:
+
+	Un archivo bootstrap mueve una cadena entre maquinas sin copiar el directorio de badger. Tiene
+	los bloques de la cadena principal desde el bloque génesis hasta la punta, en orden de altura,
+	cada uno con su largo y un checksum asi un archivo que se corto o se daño se detecta antes de
+	decodificar sus bloques. Los números son big endian.
+
+	La importación no confía en el archivo: cada bloque se comprueba como un bloque recibido de un
+	par, prueba de trabajo, bloque anterior, altura, dificultad, timestamp y transacciones, y la
+	importación se detiene en el primer bloque que rompe las reglas. Los bloques que la base de
+	datos ya tiene se saltan, asi un archivo se puede importar de nuevo o sobre una cadena que
+	tiene algunos de sus bloques, y una red sin cadena se crea con el bloque génesis del archivo.
+*/
+
+const (
+	bootstrapVersion   = 1
+	maxBootstrapRecord = 32 << 20 // bytes of a block at most, a broken length can't make the import allocate more
+)
+
+// bootstrapMagic : first bytes of a bootstrap file
+var bootstrapMagic = []byte("BCBOOT")
+
+// ImportStats : progress of the import of a bootstrap file
+type ImportStats struct {
+	Total    int // blocks in the file
+	Read     int // blocks read from the file
+	Imported int // blocks added to the database
+	Known    int // blocks that the database already had
+	Height   int // height of the tip of the main chain
+}
+
+// ExportChain : write the blocks of the main chain to w as a bootstrap file, returns the number of blocks written
+func (chain *BlockChain) ExportChain(w io.Writer) (int, error) {
+	height, err := chain.GetBestHeight()
+	if err != nil {
+		return 0, err
+	}
+
+	out := bufio.NewWriter(w)
+	header := make([]byte, len(bootstrapMagic)+9)
+	n := copy(header, bootstrapMagic)
+	header[n] = bootstrapVersion
+	binary.BigEndian.PutUint64(header[n+1:], uint64(height+1))
+	if _, err := out.Write(header); err != nil {
+		return 0, err
+	}
+
+	iter := chain.ForwardIterator(0)
+	for iter.Height <= height {
+		block, err := iter.Next()
+		if err != nil {
+			return 0, err
+		}
+		if block == nil {
+			return 0, fmt.Errorf("%w: the height index has no block at height %d", ErrBlockNotFound, iter.Height)
+		}
+
+		encoded, err := block.Serialize()
+		if err != nil {
+			return 0, err
+		}
+		record := make([]byte, 8)
+		binary.BigEndian.PutUint32(record, uint32(len(encoded)))
+		copy(record[4:], wallet.Checksum(encoded))
+
+		if _, err := out.Write(record); err != nil {
+			return 0, err
+		}
+		if _, err := out.Write(encoded); err != nil {
+			return 0, err
+		}
+	}
+
+	return height + 1, out.Flush()
+}
+
+// ImportChain : add the blocks of a bootstrap file to the chain of the options, the chain is created with the
+// genesis block of the file when the network has none. The first block that breaks the rules stops the import
+// and is reported with a *VerifyError, onProgress receives the stats after each block when it's not nil
+func ImportChain(r io.Reader, opts Options, onProgress func(ImportStats)) (ImportStats, error) {
+	var stats ImportStats
+
+	params, err := opts.Params()
+	if err != nil {
+		return stats, err
+	}
+
+	in := bufio.NewReader(r)
+	if stats.Total, err = readBootstrapHeader(in); err != nil {
+		return stats, err
+	}
+
+	genesis, err := readBootstrapBlock(in, 0)
+	if err != nil {
+		return stats, err
+	}
+	stats.Read++
+
+	chain, err := ContinueBlockChain(opts)
+	switch {
+	case errors.Is(err, ErrNoChain):
+		if err := checkGenesis(genesis, params); err != nil {
+			return stats, invalidBlock(genesis, err)
+		}
+		if chain, err = initChain(genesis, params, opts); err != nil {
+			return stats, err
+		}
+		stats.Imported++
+	case err != nil:
+		return stats, err
+	default:
+		hash, err := chain.GenesisHash()
+		if err != nil {
+			chain.Close()
+			return stats, err
+		}
+		if !bytes.Equal(hash, genesis.Hash) {
+			chain.Close()
+			return stats, fmt.Errorf("%w: the file has the genesis block %x of another chain, ours is %x", ErrInvalidBootstrap, genesis.Hash, hash)
+		}
+		stats.Known++
+	}
+	defer chain.Close()
+
+	progress := func() error {
+		height, err := chain.GetBestHeight()
+		if err != nil {
+			return err
+		}
+		stats.Height = height
+		if onProgress != nil {
+			onProgress(stats)
+		}
+		return nil
+	}
+	if err := progress(); err != nil {
+		return stats, err
+	}
+
+	prev := genesis
+	for stats.Read < stats.Total {
+		block, err := readBootstrapBlock(in, stats.Read)
+		if err != nil {
+			return stats, err
+		}
+		stats.Read++
+
+		if !bytes.Equal(block.PrevHash, prev.Hash) {
+			return stats, invalidBlock(block, fmt.Errorf("%w: previous hash %x, the block before it in the file is %x", ErrInvalidBlock, block.PrevHash, prev.Hash))
+		}
+
+		found, err := chain.HasBlock(block.Hash)
+		if err != nil {
+			return stats, err
+		}
+		if found { // the stored block was checked when it was added, the header proves the file has the same one
+			err = chain.ValidateHeader(block)
+		} else {
+			_, err = chain.AcceptBlock(block)
+		}
+		if err != nil {
+			return stats, invalidBlock(block, err)
+		}
+		if found {
+			stats.Known++
+		} else {
+			stats.Imported++
+		}
+
+		if err := progress(); err != nil {
+			return stats, err
+		}
+		prev = block
+	}
+
+	if _, err := in.ReadByte(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("%w: there is data after the last block", ErrInvalidBootstrap)
+		}
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// invalidBlock : the error of a block of the file, the blocks that break the rules are reported with a *VerifyError
+func invalidBlock(block *Block, err error) error {
+	if errors.Is(err, ErrInvalidBlock) || errors.Is(err, ErrInvalidTx) {
+		return &VerifyError{block.Height, block.Hash, err}
+	}
+
+	return err
+}
+
+// checkGenesis : check the genesis block of a file that creates the chain of the network
+func checkGenesis(block *Block, params ChainParams) error {
+	if len(block.PrevHash) != 0 {
+		return fmt.Errorf("%w: the first block of the file has the previous hash %x", ErrInvalidBlock, block.PrevHash)
+	}
+	if err := (&BlockChain{Params: params}).ValidateHeader(block); err != nil { // the header of a genesis block is checked without the database
+		return err
+	}
+
+	if len(block.Transactions) != 1 || !block.Transactions[0].IsCoinbase() {
+		return fmt.Errorf("%w: the genesis block must only have a coinbase", ErrInvalidBlock)
+	}
+	coinbase := block.Transactions[0]
+	if err := coinbase.checkID(); err != nil {
+		return err
+	}
+	if string(coinbase.Inputs[0].PubKey) != params.GenesisData {
+		return fmt.Errorf("%w: the genesis block is not of this network", ErrInvalidBlock)
+	}
+
+	return checkCoinbase(coinbase, params.Subsidy(0), 0) // the genesis block has no fees
+}
+
+// readBootstrapHeader : check the header of a bootstrap file, returns the number of blocks of the file
+func readBootstrapHeader(in io.Reader) (int, error) {
+	header := make([]byte, len(bootstrapMagic)+9)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return 0, fmt.Errorf("%w: header: %v", ErrInvalidBootstrap, err)
+	}
+	if !bytes.Equal(header[:len(bootstrapMagic)], bootstrapMagic) {
+		return 0, fmt.Errorf("%w: the file doesn't start with %q", ErrInvalidBootstrap, bootstrapMagic)
+	}
+
+	n := len(bootstrapMagic)
+	if header[n] != bootstrapVersion {
+		return 0, fmt.Errorf("%w: unknown version %d", ErrInvalidBootstrap, header[n])
+	}
+	count := binary.BigEndian.Uint64(header[n+1:])
+	if count == 0 || count > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: the header says the file has %d blocks", ErrInvalidBootstrap, count)
+	}
+
+	return int(count), nil
+}
+
+// readBootstrapBlock : read the record of the block at the height and check its checksum
+func readBootstrapBlock(in io.Reader, height int) (*Block, error) {
+	record := make([]byte, 8)
+	if _, err := io.ReadFull(in, record); err != nil {
+		return nil, fmt.Errorf("%w: block %d: %v", ErrInvalidBootstrap, height, err)
+	}
+
+	length := binary.BigEndian.Uint32(record)
+	if length > maxBootstrapRecord {
+		return nil, fmt.Errorf("%w: block %d has %d bytes", ErrInvalidBootstrap, height, length)
+	}
+	encoded := make([]byte, length)
+	if _, err := io.ReadFull(in, encoded); err != nil {
+		return nil, fmt.Errorf("%w: block %d: %v", ErrInvalidBootstrap, height, err)
+	}
+	if !bytes.Equal(wallet.Checksum(encoded), record[4:]) {
+		return nil, fmt.Errorf("%w: block %d doesn't match its checksum", ErrInvalidBootstrap, height)
+	}
+
+	block, err := Deserialize(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: block %d: %v", ErrInvalidBootstrap, height, err)
+	}
+	if block.Height != height { // the blocks are in height order
+		return nil, fmt.Errorf("%w: block %d has the height %d", ErrInvalidBootstrap, height, block.Height)
+	}
+
+	return block, nil
+}
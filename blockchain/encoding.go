@@ -0,0 +1,350 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+)
+
+/*
+	Blocks, transactions, unspent outputs and undo data are stored and sent to other nodes with a
+	binary format of their own instead of gob, whose bytes depend on the Go types and on the order
+	in which each process registers them. Every record starts with a version byte, encodingVersion,
+	so fields can be added later by writing a new version that the decoders still know how to read.
+	After the version the fields are written in a fixed order with three kinds of values:
+
+		int    signed varint of encoding/binary (zigzag), used for every number of the structs
+		count  unsigned varint, the number of items of a list
+		bytes  unsigned varint with the length followed by the bytes
+
+	A number must use the fewest bytes possible and no bytes may follow the last field, so each
+	value has exactly one encoding. The records are:
+
+		transaction  version, body
+		body         int Version, bytes ID, count inputs, count outputs, where
+		             input = bytes ID, int Out, bytes Signature, bytes PubKey
+		             output = int Value, bytes PubKeyHash
+		header       int Version, bytes PrevHash, bytes MerkleRoot, int Timestamp, int Difficulty,
+		             int Nonce, int Height
+		block        version, header, bytes Hash, count of transaction bodies
+		outputs      version, count of (int index, output) sorted by index
+		undo         version, count of (bytes ID, int Out, output)
+
+	The version 1 wrote the body without the Version of the transaction, those transactions are
+	decoded with the version 1. The same format is hashed: the ID of a transaction of version 2 is
+	the hash of its body with an empty ID and the hash of a block of version 3 is the hash of its
+	header, without the version byte so a new version of the records doesn't change them. Older
+	transactions and blocks keep the hashes of hashData and BlockHeader.Data, that were computed
+	field by field with numbers of 8 bytes, so the IDs and hashes of the chains stored with gob are
+	still valid.
+
+	The records that a database still has in gob are rewritten by the migration to schema 1. Data
+	that doesn't start with a known version is decoded as gob, a gob stream never starts with the
+	byte 1 or 2 because its first message is the definition of a type, which is longer than that.
+
+	Esp:
+
+	Los bloques, transacciones, outputs no gastados y datos de deshacer se guardan y se envían a
+	otros nodos con un formato binario propio en vez de gob, cuyos bytes dependen de los tipos de Go
+	y del orden en que cada proceso los registra. Cada registro empieza con un byte de versión,
+	encodingVersion, asi se pueden agregar campos mas adelante escribiendo una versión nueva que los
+	decodificadores todavía sepan leer. Después de la versión los campos se escriben en un orden
+	fijo con tres tipos de valores: int, un varint con signo de encoding/binary (zigzag) usado para
+	cada número de los structs, count, un varint sin signo con el número de elementos de una lista,
+	y bytes, un varint sin signo con el largo seguido de los bytes.
+
+	Un número debe usar la menor cantidad de bytes posible y ningún byte puede seguir al último
+	campo, asi cada valor tiene exactamente una codificación. Los registros son los de la tabla de
+	arriba.
+
+	La versión 1 escribía el cuerpo sin la versión de la transacción, esas transacciones se
+	decodifican con la versión 1. El mismo formato se hashea: el ID de una transacción de versión 2
+	es el hash de su cuerpo con un ID vacío y el hash de un bloque de versión 3 es el hash de su
+	header, sin el byte de versión asi una versión nueva de los registros no los cambia. Las
+	transacciones y bloques anteriores mantienen los hashes de hashData y BlockHeader.Data, que se
+	calculaban campo por campo con números de 8 bytes, asi los IDs y hashes de las cadenas
+	guardadas con gob siguen siendo validos.
+
+	Los registros que una base de datos todavía tiene en gob se reescriben en la migración al
+	esquema 1. Los datos que no empiezan con una versión conocida se decodifican como gob, un stream
+	de gob nunca empieza con el byte 1 o 2 porque su primer mensaje es la definición de un tipo, que
+	es mas largo que eso.
+*/
+
+// encodingVersion : version of the binary format written by the encoders, the version 2 writes the Version of the transactions
+const encodingVersion = 2
+
+// encoder : writes the fields of a record
+type encoder struct {
+	buffer bytes.Buffer
+}
+
+// newEncoder : encoder of a record that starts with the version byte
+func newEncoder() *encoder {
+	e := &encoder{}
+	e.buffer.WriteByte(encodingVersion)
+
+	return e
+}
+
+// writeCount : unsigned varint
+func (e *encoder) writeCount(n int) {
+	var buff [binary.MaxVarintLen64]byte
+	e.buffer.Write(buff[:binary.PutUvarint(buff[:], uint64(n))])
+}
+
+// writeInt : signed varint
+func (e *encoder) writeInt(n int64) {
+	var buff [binary.MaxVarintLen64]byte
+	e.buffer.Write(buff[:binary.PutVarint(buff[:], n)])
+}
+
+// writeBytes : the length of the bytes followed by the bytes
+func (e *encoder) writeBytes(b []byte) {
+	e.writeCount(len(b))
+	e.buffer.Write(b)
+}
+
+// writeOutput : the fields of the output
+func (e *encoder) writeOutput(out TxOutput) {
+	e.writeInt(int64(out.Value))
+	e.writeBytes(out.PubKeyHash)
+}
+
+// writeHeader : the fields of the header
+func (e *encoder) writeHeader(h BlockHeader) {
+	e.writeInt(int64(h.Version))
+	e.writeBytes(h.PrevHash)
+	e.writeBytes(h.MerkleRoot)
+	e.writeInt(h.Timestamp)
+	e.writeInt(int64(h.Difficulty))
+	e.writeInt(int64(h.Nonce))
+	e.writeInt(int64(h.Height))
+}
+
+// writeTx : the body of the transaction
+func (e *encoder) writeTx(tx *Transaction) {
+	e.writeInt(int64(tx.Version))
+	e.writeBytes(tx.ID)
+	e.writeCount(len(tx.Inputs))
+	for _, in := range tx.Inputs {
+		e.writeBytes(in.ID)
+		e.writeInt(int64(in.Out))
+		e.writeBytes(in.Signature)
+		e.writeBytes(in.PubKey)
+	}
+	e.writeCount(len(tx.Outputs))
+	for _, out := range tx.Outputs {
+		e.writeOutput(out)
+	}
+}
+
+// decoder : reads the fields of a record, the first error stops the reads and is kept in err
+type decoder struct {
+	data    []byte
+	version byte // version of the record
+	err     error
+}
+
+// newDecoder : decoder of a record encoded with any of the versions up to the one that the encoders write
+func newDecoder(data []byte) *decoder {
+	d := &decoder{data: data}
+	if len(data) == 0 || !knownVersion(data[0]) {
+		d.fail("unknown version")
+		return d
+	}
+	d.version = data[0]
+	d.data = data[1:]
+
+	return d
+}
+
+// fail : stop decoding with the error
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrInvalidEncoding, fmt.Sprintf(format, a...))
+	}
+}
+
+// readUvarint : unsigned varint that uses the fewest bytes possible
+func (d *decoder) readUvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	n, size := binary.Uvarint(d.data)
+	var buff [binary.MaxVarintLen64]byte
+	if size <= 0 || size != binary.PutUvarint(buff[:], n) {
+		d.fail("bad varint")
+		return 0
+	}
+	d.data = d.data[size:]
+
+	return n
+}
+
+// readCount : number of items of a list, every item takes at least one byte so it can't be more than the bytes left
+func (d *decoder) readCount() int {
+	n := d.readUvarint()
+	if n > uint64(len(d.data)) {
+		d.fail("%d items in %d bytes", n, len(d.data))
+		return 0
+	}
+
+	return int(n)
+}
+
+// readInt : signed varint that fits in an int
+func (d *decoder) readInt() int {
+	n := d.readInt64()
+	if int64(int(n)) != n {
+		d.fail("%d overflows an int", n)
+		return 0
+	}
+
+	return int(n)
+}
+
+// readInt64 : signed varint that uses the fewest bytes possible
+func (d *decoder) readInt64() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	n, size := binary.Varint(d.data)
+	var buff [binary.MaxVarintLen64]byte
+	if size <= 0 || size != binary.PutVarint(buff[:], n) {
+		d.fail("bad varint")
+		return 0
+	}
+	d.data = d.data[size:]
+
+	return n
+}
+
+// readBytes : bytes preceded by their length, nil when the length is 0
+func (d *decoder) readBytes() []byte {
+	n := d.readUvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.data)) {
+		d.fail("%d bytes expected, %d left", n, len(d.data))
+		return nil
+	}
+	if n == 0 {
+		return nil
+	}
+
+	b := make([]byte, n)
+	copy(b, d.data)
+	d.data = d.data[n:]
+
+	return b
+}
+
+// readOutput : the fields of an output
+func (d *decoder) readOutput() TxOutput {
+	return TxOutput{Value: d.readInt(), PubKeyHash: d.readBytes()}
+}
+
+// readHeader : the fields of a header
+func (d *decoder) readHeader() BlockHeader {
+	return BlockHeader{
+		Version:    d.readInt(),
+		PrevHash:   d.readBytes(),
+		MerkleRoot: d.readBytes(),
+		Timestamp:  d.readInt64(),
+		Difficulty: d.readInt(),
+		Nonce:      d.readInt(),
+		Height:     d.readInt(),
+	}
+}
+
+// readTx : the body of a transaction
+func (d *decoder) readTx() *Transaction {
+	tx := &Transaction{Version: 1} // the version 1 of the records didn't write it
+	if d.version >= 2 {
+		tx.Version = d.readInt()
+	}
+	tx.ID = d.readBytes()
+
+	if n := d.readCount(); n > 0 {
+		tx.Inputs = make([]TxInput, 0, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			tx.Inputs = append(tx.Inputs, TxInput{
+				ID:        d.readBytes(),
+				Out:       d.readInt(),
+				Signature: d.readBytes(),
+				PubKey:    d.readBytes(),
+			})
+		}
+	}
+	if n := d.readCount(); n > 0 {
+		tx.Outputs = make([]TxOutput, 0, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			tx.Outputs = append(tx.Outputs, d.readOutput())
+		}
+	}
+
+	return tx
+}
+
+// finish : the error of the decoder, bytes left after the last field are an error too
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.data) > 0 {
+		d.fail("%d bytes after the last field", len(d.data))
+	}
+
+	return d.err
+}
+
+// knownVersion : the version byte is one of the versions that the decoders can read
+func knownVersion(version byte) bool {
+	return version >= 1 && version <= encodingVersion
+}
+
+// isLegacy : the data was encoded with gob before the binary format existed
+func isLegacy(data []byte) bool {
+	return len(data) > 0 && !knownVersion(data[0])
+}
+
+// decodeLegacy : decode gob data into v
+func decodeLegacy(data []byte, v interface{}) error {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	return nil
+}
+
+// encodeUndo : binary format of the outputs spent by a block
+func encodeUndo(spent []spentOutput) []byte {
+	e := newEncoder()
+	e.writeCount(len(spent))
+	for _, s := range spent {
+		e.writeBytes(s.ID)
+		e.writeInt(int64(s.Out))
+		e.writeOutput(s.Output)
+	}
+
+	return e.buffer.Bytes()
+}
+
+// decodeUndo : decode the outputs spent by a block encoded with encodeUndo or with gob
+func decodeUndo(data []byte) ([]spentOutput, error) {
+	var spent []spentOutput
+	if isLegacy(data) {
+		err := decodeLegacy(data, &spent)
+		return spent, err
+	}
+
+	d := newDecoder(data)
+	n := d.readCount()
+	for i := 0; i < n && d.err == nil; i++ {
+		spent = append(spent, spentOutput{ID: d.readBytes(), Out: d.readInt(), Output: d.readOutput()})
+	}
+
+	return spent, d.finish()
+}
@@ -0,0 +1,52 @@
+package blockchain
+
+import "errors"
+
+/*
+	The package never panics or stops the goroutine of the caller, every failure is returned as an
+	error. The errors that the caller may want to handle are exported so they can be compared with
+	errors.Is, the rest of the errors wrap them with more details.
+
+	Esp:
+
+	El paquete nunca entra en panic ni detiene la goroutine del que lo llama, cada falla se retorna
+	como un error. Los errores que el que llama podría querer manejar son exportados para que se
+	puedan comparar con errors.Is, el resto de los errores los envuelven con mas detalles.
+*/
+
+var (
+	// ErrChainExists : InitBlockChain was called on a database that already has a chain
+	ErrChainExists = errors.New("blockchain already exists")
+	// ErrNoChain : there is no database to continue
+	ErrNoChain = errors.New("no existing blockchain found, create one")
+	// ErrUnknownNetwork : there are no chain parameters for the network
+	ErrUnknownNetwork = errors.New("unknown network")
+	// ErrBlockNotFound : there is no block with the hash
+	ErrBlockNotFound = errors.New("block not found")
+	// ErrTxNotFound : there is no transaction with the ID
+	ErrTxNotFound = errors.New("transaction not found")
+	// ErrInsufficientFunds : the unspent outputs of the sender don't add up to the amount
+	ErrInsufficientFunds = errors.New("not enough funds")
+	// ErrInvalidTx : a transaction breaks the rules of the chain
+	ErrInvalidTx = errors.New("invalid transaction")
+	// ErrDoubleSpend : the transaction spends an output that a pending transaction already spends
+	ErrDoubleSpend = errors.New("double spend")
+	// ErrKnownTx : the transaction is already in the mempool
+	ErrKnownTx = errors.New("transaction already in the mempool")
+	// ErrInvalidBlock : a block breaks the rules of the chain
+	ErrInvalidBlock = errors.New("invalid block")
+	// ErrNonceSpaceExhausted : every nonce was tried without finding a valid hash
+	ErrNonceSpaceExhausted = errors.New("nonce space exhausted")
+	// ErrStaleBlock : the tip of the chain changed while the block was being mined
+	ErrStaleBlock = errors.New("the chain tip changed while the block was being mined")
+	// ErrOrphanBlock : the previous block of the block is unknown, the block is held until it arrives
+	ErrOrphanBlock = errors.New("orphan block")
+	// ErrInvalidEncoding : the bytes are not a valid encoding of a block, transaction or outputs
+	ErrInvalidEncoding = errors.New("invalid encoding")
+	// ErrSchemaOutdated : the database uses the layout of an older version and has to be migrated
+	ErrSchemaOutdated = errors.New("outdated database schema")
+	// ErrSchemaTooNew : the database was written by a newer version of the program
+	ErrSchemaTooNew = errors.New("database schema too new")
+	// ErrInvalidBootstrap : the file is not a bootstrap file or it is damaged
+	ErrInvalidBootstrap = errors.New("invalid bootstrap file")
+)
@@ -0,0 +1,333 @@
+package blockchain
+
+import (
+	"sync"
+
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+/*
+	The event bus tells other parts of the program what happens to the chain as soon as it happens,
+	instead of making them read the database again and again. The events of a block are published
+	after the badger transaction that moves the tip is committed, for every block that leaves the
+	main chain in a reorganization and then for every block that joins it, so the events follow the
+	order in which the database changed. Publishing never waits for a subscriber: the events of a
+	block are queued together in a single place of the queue of each subscriber, however many
+	transactions the block has, and a subscriber that lets eventBuffer blocks or mempool
+	transactions pile up is dropped, its channel is closed after the events already queued.
+
+	Esp:
+
+	El bus de eventos le dice a otras partes del programa lo que le pasa a la cadena en el momento
+	en que pasa, en vez de hacerlas leer la base de datos una y otra vez. Los eventos de un bloque
+	se publican después de confirmar la transacción de badger que mueve la punta, para cada bloque
+	que sale de la cadena principal en una reorganización y luego para cada bloque que entra en
+	ella, asi los eventos siguen el orden en que cambió la base de datos. Publicar nunca espera a un
+	suscriptor: los eventos de un bloque se encolan juntos en un solo lugar de la cola de cada
+	suscriptor, sin importar cuantas transacciones tenga el bloque, y un suscriptor que deja
+	acumular eventBuffer bloques o transacciones del mempool es eliminado, su canal se cierra
+	después de los eventos que ya estaban en la cola.
+*/
+
+// EventType : what happened to the chain or the mempool
+type EventType string
+
+// Types of the events published by the chain
+const (
+	EventBlockConnected    EventType = "blockconnected"    // a block joined the main chain
+	EventBlockDisconnected EventType = "blockdisconnected" // a block left the main chain in a reorganization
+	EventTxConfirmed       EventType = "txconfirmed"       // a transaction was included in a block of the main chain
+	EventTxAccepted        EventType = "txaccepted"        // a transaction was added to the mempool
+	EventAddressReceived   EventType = "addressreceived"   // a confirmed transaction sent coins to an address
+)
+
+// eventBuffer : blocks and mempool transactions that a subscriber can have pending before it is dropped
+const eventBuffer = 256
+
+// Event : something that happened to the chain or the mempool
+type Event struct {
+	Type      EventType
+	BlockHash []byte   // nil for a transaction of the mempool
+	Height    int      // -1 for a transaction of the mempool
+	TxIDs     [][]byte // transactions affected by the event
+	Addresses []string // addresses of the inputs and outputs of the transactions
+	Value     int      // coins received by the address of an EventAddressReceived
+}
+
+// EventFilter : the events wanted by a subscriber, an empty list matches everything
+type EventFilter struct {
+	Types     []EventType
+	Addresses []string
+}
+
+// Match : the event has one of the types and involves one of the addresses of the filter
+func (filter EventFilter) Match(event Event) bool {
+	if len(filter.Types) > 0 {
+		found := false
+		for _, t := range filter.Types {
+			if t == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(filter.Addresses) == 0 {
+		return true
+	}
+	for _, address := range filter.Addresses {
+		for _, involved := range event.Addresses {
+			if address == involved {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// EventBus : delivers the events of a chain to its subscribers
+type EventBus struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription : the events that match the filter are sent to C until the subscription is closed
+type Subscription struct {
+	C <-chan Event
+
+	bus     *EventBus
+	batches chan []Event  // events waiting to be sent to C, the ones of a block are a single batch
+	quit    chan struct{} // closed by Close, the events still queued are discarded
+	filter  EventFilter
+	dropped bool
+}
+
+// NewEventBus : bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe : receive the events that match the filter
+func (bus *EventBus) Subscribe(filter EventFilter) *Subscription {
+	ch := make(chan Event)
+	sub := &Subscription{
+		C:       ch,
+		bus:     bus,
+		batches: make(chan []Event, eventBuffer),
+		quit:    make(chan struct{}),
+		filter:  filter,
+	}
+	go sub.forward(ch)
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if bus.closed {
+		close(sub.batches)
+		return sub
+	}
+	bus.subs[sub] = struct{}{}
+
+	return sub
+}
+
+// Publish : send the event to every subscriber whose filter matches it, a nil bus does nothing
+func (bus *EventBus) Publish(event Event) {
+	bus.publish([]Event{event})
+}
+
+// publish : queue the events that match the filter of each subscriber as a single batch
+func (bus *EventBus) publish(events []Event) {
+	if bus == nil {
+		return
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	for sub := range bus.subs {
+		var matched []Event
+		for _, event := range events {
+			if sub.filter.Match(event) {
+				matched = append(matched, event)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		select {
+		case sub.batches <- matched:
+		default: // too slow, the chain doesn't wait for it
+			sub.dropped = true
+			bus.remove(sub)
+		}
+	}
+}
+
+// Close : close the channels of every subscriber, the events published later are discarded
+func (bus *EventBus) Close() {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	for sub := range bus.subs {
+		bus.remove(sub)
+	}
+	bus.closed = true
+}
+
+// remove : forget the subscriber, its channel is closed after the events already queued, the lock must be held
+func (bus *EventBus) remove(sub *Subscription) {
+	if _, ok := bus.subs[sub]; !ok {
+		return
+	}
+	delete(bus.subs, sub)
+	close(sub.batches)
+}
+
+// forward : send the queued events to C one at a time, C is closed when the queue is closed and empty or
+// when the subscription is closed
+func (sub *Subscription) forward(ch chan<- Event) {
+	defer close(ch)
+
+	for batch := range sub.batches {
+		for _, event := range batch {
+			select {
+			case ch <- event:
+			case <-sub.quit:
+				return
+			}
+		}
+	}
+}
+
+// SetFilter : replace the filter of the subscription
+func (sub *Subscription) SetFilter(filter EventFilter) {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+
+	sub.filter = filter
+}
+
+// Filter : the filter of the subscription
+func (sub *Subscription) Filter() EventFilter {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+
+	return sub.filter
+}
+
+// Dropped : the subscription was closed because its events were not read fast enough
+func (sub *Subscription) Dropped() bool {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+
+	return sub.dropped
+}
+
+// Close : stop receiving events, C is closed without the events still queued
+func (sub *Subscription) Close() {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+
+	sub.bus.remove(sub)
+	select {
+	case <-sub.quit: // already closed
+	default:
+		close(sub.quit)
+	}
+}
+
+// publishChange : the events of the blocks that left and joined the main chain, a batch for each block
+func (bus *EventBus) publishChange(change *TipChange) {
+	if bus == nil {
+		return
+	}
+
+	for _, block := range change.Disconnected {
+		bus.Publish(blockEvent(EventBlockDisconnected, block))
+	}
+
+	for _, block := range change.Connected {
+		events := []Event{blockEvent(EventBlockConnected, block)}
+
+		for _, tx := range block.Transactions {
+			events = append(events, Event{
+				Type:      EventTxConfirmed,
+				BlockHash: block.Hash,
+				Height:    block.Height,
+				TxIDs:     [][]byte{tx.ID},
+				Addresses: txAddresses(tx),
+			})
+
+			received := make(map[string]int)
+			var order []string // addresses in the order of the outputs
+			for _, out := range tx.Outputs {
+				address := string(wallet.AddressFromPubKeyHash(out.PubKeyHash))
+				if _, ok := received[address]; !ok {
+					order = append(order, address)
+				}
+				received[address] += out.Value
+			}
+			for _, address := range order {
+				events = append(events, Event{
+					Type:      EventAddressReceived,
+					BlockHash: block.Hash,
+					Height:    block.Height,
+					TxIDs:     [][]byte{tx.ID},
+					Addresses: []string{address},
+					Value:     received[address],
+				})
+			}
+		}
+
+		bus.publish(events)
+	}
+}
+
+// blockEvent : event of the block with the IDs and addresses of all of its transactions
+func blockEvent(eventType EventType, block *Block) Event {
+	event := Event{Type: eventType, BlockHash: block.Hash, Height: block.Height}
+	seen := make(map[string]bool)
+
+	for _, tx := range block.Transactions {
+		event.TxIDs = append(event.TxIDs, tx.ID)
+		for _, address := range txAddresses(tx) {
+			if !seen[address] {
+				seen[address] = true
+				event.Addresses = append(event.Addresses, address)
+			}
+		}
+	}
+
+	return event
+}
+
+// txAddresses : the addresses that sign the inputs of the transaction and receive its outputs
+func txAddresses(tx *Transaction) []string {
+	var addresses []string
+	seen := make(map[string]bool)
+	add := func(pubKeyHash []byte) {
+		address := string(wallet.AddressFromPubKeyHash(pubKeyHash))
+		if !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+
+	if !tx.IsCoinbase() {
+		for _, in := range tx.Inputs {
+			add(wallet.PublicKeyHash(in.PubKey))
+		}
+	}
+	for _, out := range tx.Outputs {
+		add(out.PubKeyHash)
+	}
+
+	return addresses
+}
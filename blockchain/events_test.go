@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+// receive : the events sent to the subscription until its channel is closed
+func receive(t *testing.T, sub *Subscription) []Event {
+	t.Helper()
+
+	var events []Event
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		case <-timeout:
+			t.Fatalf("the channel was not closed after %d events", len(events))
+		}
+	}
+}
+
+func TestBlockEventsTakeOnePlace(t *testing.T) {
+	bus := NewEventBus()
+	sub := bus.Subscribe(EventFilter{})
+
+	const txs = eventBuffer/2 + 1 // 1+txs*2 events for each block
+	var blocks []*Block
+	for i := 0; i < eventBuffer; i++ { // a full queue of blocks that don't fit in eventBuffer events
+		block := &Block{Hash: []byte{byte(i)}}
+		block.Height = i
+		for j := 0; j < txs; j++ {
+			block.Transactions = append(block.Transactions, &Transaction{
+				ID:      []byte{byte(j >> 8), byte(j)},
+				Inputs:  []TxInput{{[]byte{}, -1, nil, []byte("data")}},
+				Outputs: []TxOutput{{10, []byte{byte(j)}}},
+			})
+		}
+		blocks = append(blocks, block)
+	}
+	bus.publishChange(&TipChange{Connected: blocks})
+	bus.Close()
+
+	events := receive(t, sub)
+	if sub.Dropped() {
+		t.Fatal("the subscriber was dropped")
+	}
+	if expected := eventBuffer * (1 + txs*2); len(events) != expected {
+		t.Fatalf("%d events, expected %d", len(events), expected)
+	}
+	if events[0].Type != EventBlockConnected || events[1].Type != EventTxConfirmed || events[2].Type != EventAddressReceived {
+		t.Errorf("the events of a block are out of order: %s %s %s", events[0].Type, events[1].Type, events[2].Type)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	bus := NewEventBus()
+	sub := bus.Subscribe(EventFilter{})
+	other := bus.Subscribe(EventFilter{Types: []EventType{EventBlockConnected}}) // its filter matches none of them
+
+	for i := 0; i < eventBuffer+2; i++ {
+		bus.Publish(Event{Type: EventTxAccepted, Height: -1, TxIDs: [][]byte{{byte(i)}}})
+	}
+
+	events := receive(t, sub)
+	if !sub.Dropped() {
+		t.Error("the subscriber that didn't read its events was not dropped")
+	}
+	if len(events) < eventBuffer {
+		t.Errorf("%d events were sent before the channel was closed, expected the %d queued", len(events), eventBuffer)
+	}
+
+	if other.Dropped() {
+		t.Error("a subscriber without matching events was dropped")
+	}
+	other.Close()
+	if _, ok := <-other.C; ok {
+		t.Error("the channel is open after Close")
+	}
+}
@@ -0,0 +1,261 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+// AddressTx : a transaction of the main chain that sends coins to or from an address
+type AddressTx struct {
+	TxID           []byte
+	BlockHash      []byte
+	Height         int
+	Position       int // position of the transaction inside of its block
+	Timestamp      int64
+	Coinbase       bool
+	Received       int      // value of the outputs of the transaction locked to the address
+	Sent           int      // value of the outputs of the address spent by the inputs of the transaction
+	Balance        int      // balance of the address after the transaction
+	Counterparties []string // the receivers when the address sends coins, the senders when it only receives them
+}
+
+// Direction : received, sent or both when the address pays itself some change
+func (entry AddressTx) Direction() string {
+	switch {
+	case entry.Sent == 0:
+		return "received"
+	case entry.Received == 0:
+		return "sent"
+	default:
+		return "both"
+	}
+}
+
+// AddressHistory : the transactions of the main chain that involve the key, from the newest to the oldest,
+// skipping the first offset of them and returning at most limit, or all of them when limit is 0.
+// The address index is used when the chain has one, otherwise the whole chain is read
+func (chain *BlockChain) AddressHistory(pubKeyHash []byte, offset, limit int) ([]AddressTx, error) {
+	var history []AddressTx
+	var err error
+
+	if chain.addressIndex {
+		history, err = chain.indexedHistory(pubKeyHash)
+	} else {
+		history, err = chain.scanHistory(pubKeyHash)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	balance := 0
+	for i := len(history) - 1; i >= 0; i-- { // from the oldest
+		balance += history[i].Received - history[i].Sent
+		history[i].Balance = balance
+	}
+
+	if offset >= len(history) {
+		return nil, nil
+	}
+	history = history[offset:]
+	if limit > 0 && len(history) > limit {
+		history = history[:limit]
+	}
+
+	address := string(wallet.AddressFromPubKeyHash(pubKeyHash))
+	for i := range history {
+		block, err := chain.GetBlockByHeight(history[i].Height)
+		if err != nil {
+			return nil, err
+		}
+		tx := block.Transactions[history[i].Position]
+
+		history[i].BlockHash = block.Hash
+		history[i].Timestamp = block.Timestamp
+		history[i].Coinbase = tx.IsCoinbase()
+		history[i].Counterparties = counterparties(tx, address, history[i].Sent > 0)
+	}
+
+	return history, nil
+}
+
+// counterparties : the other addresses that receive the outputs of the transaction when the address
+// sends it, or that sign its inputs when the address only receives coins
+func counterparties(tx *Transaction, address string, sent bool) []string {
+	var addresses []string
+	seen := map[string]bool{address: true}
+	add := func(pubKeyHash []byte) {
+		other := string(wallet.AddressFromPubKeyHash(pubKeyHash))
+		if !seen[other] {
+			seen[other] = true
+			addresses = append(addresses, other)
+		}
+	}
+
+	if sent {
+		for _, out := range tx.Outputs {
+			add(out.PubKeyHash)
+		}
+	} else if !tx.IsCoinbase() {
+		for _, in := range tx.Inputs {
+			add(wallet.PublicKeyHash(in.PubKey))
+		}
+	}
+
+	return addresses
+}
+
+// indexedHistory : every transaction of the key in the address index, from the newest to the oldest
+func (chain *BlockChain) indexedHistory(pubKeyHash []byte) ([]AddressTx, error) {
+	var history []AddressTx
+	prefix := addressPrefix(pubKeyHash)
+
+	err := chain.store.View(func(txn Txn) error {
+		it := txn.NewIterator(prefix, false)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := it.Key()[len(prefix):]
+			if len(key) != addressKeyLength { // the key of another hash that starts with this one
+				continue
+			}
+			v, err := it.Value()
+			if err != nil {
+				return err
+			}
+
+			entry, err := decodeAddressEntry(key, v)
+			if err != nil {
+				return err
+			}
+			history = append(history, entry)
+		}
+
+		return nil
+	})
+
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 { // the keys are sorted from the oldest
+		history[i], history[j] = history[j], history[i]
+	}
+
+	return history, err
+}
+
+// scanHistory : every transaction of the key read from the blocks of the main chain, from the newest to the oldest
+func (chain *BlockChain) scanHistory(pubKeyHash []byte) ([]AddressTx, error) {
+	var history []AddressTx
+	var spends [][]string           // outpoints spent by each transaction of the history
+	outputs := make(map[string]int) // value of every output locked to the key
+
+	iter := chain.Iterator()
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := len(block.Transactions) - 1; i >= 0; i-- { // the newest transactions first
+			tx := block.Transactions[i]
+			entry := AddressTx{TxID: tx.ID, Height: block.Height, Position: i}
+
+			var spent []string
+			if !tx.IsCoinbase() {
+				for _, in := range tx.Inputs {
+					if in.UsesKey(pubKeyHash) {
+						spent = append(spent, outpoint(in.ID, in.Out))
+					}
+				}
+			}
+			for outIdx, out := range tx.Outputs {
+				if out.IsLockedWithKey(pubKeyHash) {
+					entry.Received += out.Value
+					outputs[outpoint(tx.ID, outIdx)] = out.Value
+				}
+			}
+
+			if entry.Received > 0 || len(spent) > 0 {
+				history = append(history, entry)
+				spends = append(spends, spent)
+			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	for i := range history { // the outputs are older than the inputs that spend them, they are all known after the walk
+		for _, op := range spends[i] {
+			history[i].Sent += outputs[op]
+		}
+	}
+
+	return history, nil
+}
+
+// addressEntries : the value received and sent by every key in each transaction of the block, spent
+// has the outputs spent by the block
+func addressEntries(block *Block, spent []spentOutput) map[string][]AddressTx {
+	spentOutputs := make(map[string]TxOutput, len(spent))
+	for _, s := range spent {
+		spentOutputs[outpoint(s.ID, s.Out)] = s.Output
+	}
+
+	entries := make(map[string][]AddressTx) // by the public key hash
+	for i, tx := range block.Transactions {
+		byKey := make(map[string]*AddressTx)
+		var keys []string
+		entry := func(pubKeyHash []byte) *AddressTx {
+			key := string(pubKeyHash)
+			if _, ok := byKey[key]; !ok {
+				byKey[key] = &AddressTx{TxID: tx.ID, Height: block.Height, Position: i}
+				keys = append(keys, key)
+			}
+			return byKey[key]
+		}
+
+		if !tx.IsCoinbase() {
+			for _, in := range tx.Inputs {
+				out := spentOutputs[outpoint(in.ID, in.Out)]
+				entry(out.PubKeyHash).Sent += out.Value
+			}
+		}
+		for _, out := range tx.Outputs {
+			entry(out.PubKeyHash).Received += out.Value
+		}
+
+		for _, key := range keys {
+			entries[key] = append(entries[key], *byKey[key])
+		}
+	}
+
+	return entries
+}
+
+// encodeAddressEntry : the value of an entry of the address index, the ID of the transaction followed by
+// the received and sent values as big endian uint64
+func encodeAddressEntry(entry AddressTx) []byte {
+	value := make([]byte, len(entry.TxID)+16)
+	n := copy(value, entry.TxID)
+	binary.BigEndian.PutUint64(value[n:], uint64(entry.Received))
+	binary.BigEndian.PutUint64(value[n+8:], uint64(entry.Sent))
+
+	return value
+}
+
+// decodeAddressEntry : the entry with the height and position of the key and the value of the address index
+func decodeAddressEntry(key, value []byte) (AddressTx, error) {
+	if len(key) != addressKeyLength || len(value) < 16 {
+		return AddressTx{}, fmt.Errorf("the address index has a broken entry")
+	}
+	n := len(value) - 16
+
+	return AddressTx{
+		TxID:     append([]byte{}, value[:n]...),
+		Height:   int(binary.BigEndian.Uint64(key[:8])),
+		Position: int(binary.BigEndian.Uint32(key[8:])),
+		Received: int(binary.BigEndian.Uint64(value[n:])),
+		Sent:     int(binary.BigEndian.Uint64(value[n+8:])),
+	}, nil
+}
@@ -0,0 +1,380 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+/*
+	The blocks are stored by their hash, so without an index the only way to find the block at a
+	height is to walk the chain back from the tip. The index maps the height of every block of the
+	main chain to its hash. It is changed in the same badger transaction that connects or
+	disconnects the blocks, so it always matches the main chain, and a chain stored before the
+	index existed is indexed when it is opened.
+
+	The tx index is optional, it maps the ID of every transaction of the main chain to the hash of
+	its block and its position inside of it, so finding a transaction doesn't need to read every
+	block. It is built the first time the chain is opened with Options.TxIndex and from then on it
+	is kept up to date like the height index, even when the option is not set. The address index
+	is optional too and works the same way with Options.AddressIndex, for every public key hash it
+	has the transactions that send coins to it or spend its outputs, sorted by height and position,
+	with the value received and sent in each one.
+
+	Esp:
+
+	Los bloques se guardan por su hash, asi que sin un índice la única forma de encontrar el bloque
+	a una altura es recorrer la cadena hacia atrás desde la punta. El índice relaciona la altura de
+	cada bloque de la cadena principal con su hash. Se cambia en la misma transacción de badger que
+	conecta o desconecta los bloques, asi siempre coincide con la cadena principal, y una cadena
+	guardada antes de que existiera el índice se indexa cuando se abre.
+
+	El índice de transacciones es opcional, relaciona el ID de cada transacción de la cadena
+	principal con el hash de su bloque y su posición dentro de el, asi encontrar una transacción no
+	necesita leer cada bloque. Se construye la primera vez que la cadena se abre con
+	Options.TxIndex y desde entonces se mantiene al día como el índice de alturas, aunque la opción
+	no esté puesta. El índice de direcciones también es opcional y funciona igual con
+	Options.AddressIndex, para cada hash de llave pública tiene las transacciones que le envían
+	monedas o gastan sus outputs, ordenadas por altura y posición, con el valor recibido y enviado
+	en cada una.
+*/
+
+// indexBatch : blocks indexed in each badger transaction when a whole chain is indexed
+const indexBatch = 500
+
+// heightKey : key of the hash of the block at the height, big endian so the keys are sorted by height
+func heightKey(height int) []byte {
+	key := make([]byte, len(heightPrefix)+8)
+	copy(key, heightPrefix)
+	binary.BigEndian.PutUint64(key[len(heightPrefix):], uint64(height))
+
+	return key
+}
+
+// txKey : key of the location of the transaction with the ID
+func txKey(ID []byte) []byte {
+	return append(append([]byte{}, txPrefix...), ID...)
+}
+
+// addressKeyLength : bytes of the height and the position after the public key hash in the keys of the address index
+const addressKeyLength = 12
+
+// addressPrefix : prefix of the keys of the transactions of the public key hash in the address index
+func addressPrefix(pubKeyHash []byte) []byte {
+	return append(append([]byte{}, addrPrefix...), pubKeyHash...)
+}
+
+// addressKey : key of the transaction at the position of the block at the height in the address index
+// of the public key hash, sorted by height and then by position
+func addressKey(pubKeyHash []byte, height, position int) []byte {
+	prefix := addressPrefix(pubKeyHash)
+	key := make([]byte, len(prefix)+addressKeyLength)
+	n := copy(key, prefix)
+	binary.BigEndian.PutUint64(key[n:], uint64(height))
+	binary.BigEndian.PutUint32(key[n+8:], uint32(position))
+
+	return key
+}
+
+// connectIndex : add the block that joins the main chain to the indexes, spent has the outputs spent by the block
+func (chain *BlockChain) connectIndex(txn Txn, block *Block, spent []spentOutput) error {
+	if err := connectHeight(txn, block); err != nil {
+		return err
+	}
+	if chain.txIndex {
+		if err := connectTxs(txn, block); err != nil {
+			return err
+		}
+	}
+	if chain.addressIndex {
+		if err := connectAddresses(txn, block, spent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// disconnectIndex : remove the block that leaves the main chain from the indexes
+func (chain *BlockChain) disconnectIndex(txn Txn, block *Block) error {
+	if err := txn.Delete(heightKey(block.Height)); err != nil {
+		return err
+	}
+
+	for i, tx := range block.Transactions {
+		if chain.txIndex {
+			if err := txn.Delete(txKey(tx.ID)); err != nil {
+				return err
+			}
+		}
+		if !chain.addressIndex {
+			continue
+		}
+
+		if !tx.IsCoinbase() {
+			for _, in := range tx.Inputs {
+				if err := txn.Delete(addressKey(wallet.PublicKeyHash(in.PubKey), block.Height, i)); err != nil {
+					return err
+				}
+			}
+		}
+		for _, out := range tx.Outputs {
+			if err := txn.Delete(addressKey(out.PubKeyHash, block.Height, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// connectHeight : add the block to the height index
+func connectHeight(txn Txn, block *Block) error {
+	return txn.Set(heightKey(block.Height), block.Hash)
+}
+
+// connectTxs : add the transactions of the block to the tx index, the value is the hash of the block
+// followed by the position of the transaction as a big endian uint32
+func connectTxs(txn Txn, block *Block) error {
+	for i, tx := range block.Transactions {
+		location := make([]byte, len(block.Hash)+4)
+		copy(location, block.Hash)
+		binary.BigEndian.PutUint32(location[len(block.Hash):], uint32(i))
+
+		if err := txn.Set(txKey(tx.ID), location); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// connectAddresses : add the transactions of the block to the address index of every key that they involve
+func connectAddresses(txn Txn, block *Block, spent []spentOutput) error {
+	for pubKeyHash, entries := range addressEntries(block, spent) {
+		for _, entry := range entries {
+			if err := txn.Set(addressKey([]byte(pubKeyHash), entry.Height, entry.Position), encodeAddressEntry(entry)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasKey : the key is stored
+func hasKey(txn Txn, key []byte) (bool, error) {
+	_, err := txn.Get(key)
+	if err == ErrKeyNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// HasTxIndex : the transactions of the main chain are indexed
+func (chain *BlockChain) HasTxIndex() bool {
+	return chain.txIndex
+}
+
+// HasAddressIndex : the transactions of the main chain are indexed by the addresses that they involve
+func (chain *BlockChain) HasAddressIndex() bool {
+	return chain.addressIndex
+}
+
+// indexedTransaction : the transaction with the ID and its block found with the tx index
+func (chain *BlockChain) indexedTransaction(ID []byte) (*Transaction, *Block, error) {
+	var location []byte
+
+	err := chain.store.View(func(txn Txn) error {
+		var err error
+		location, err = txn.Get(txKey(ID))
+		if err == ErrKeyNotFound {
+			return fmt.Errorf("%w: %x", ErrTxNotFound, ID)
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(location) < 4 {
+		return nil, nil, fmt.Errorf("the tx index has a broken location for %x", ID)
+	}
+
+	blockHash := location[:len(location)-4]
+	position := int(binary.BigEndian.Uint32(location[len(location)-4:]))
+
+	block, err := chain.GetBlock(blockHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if position >= len(block.Transactions) || !bytes.Equal(block.Transactions[position].ID, ID) {
+		return nil, nil, fmt.Errorf("the tx index has a wrong location for %x", ID)
+	}
+
+	return block.Transactions[position], &block, nil
+}
+
+// BlockHashAt : the hash of the block of the main chain at the height
+func (chain *BlockChain) BlockHashAt(height int) ([]byte, error) {
+	var hash []byte
+
+	err := chain.store.View(func(txn Txn) error {
+		if height < 0 {
+			return fmt.Errorf("%w: no block at height %d", ErrBlockNotFound, height)
+		}
+		var err error
+		hash, err = txn.Get(heightKey(height))
+		if err == ErrKeyNotFound {
+			return fmt.Errorf("%w: no block at height %d", ErrBlockNotFound, height)
+		}
+
+		return err
+	})
+
+	return hash, err
+}
+
+// GetBlockByHeight : the block of the main chain at the height
+func (chain *BlockChain) GetBlockByHeight(height int) (Block, error) {
+	hash, err := chain.BlockHashAt(height)
+	if err != nil {
+		return Block{}, err
+	}
+
+	return chain.GetBlock(hash)
+}
+
+// indexChain : build the indexes that the main chain is missing, the tx and address indexes only when
+// they are requested. The blocks are indexed from the genesis block up and the marks of the optional
+// indexes are stored last, so an interrupted run is finished the next time the chain is opened
+func (chain *BlockChain) indexChain(txIndex, addressIndex bool) error {
+	tip, err := chain.GetBlock(chain.LastHash)
+	if err != nil {
+		return err
+	}
+	hash, err := chain.BlockHashAt(tip.Height)
+	if err != nil && !errors.Is(err, ErrBlockNotFound) {
+		return err
+	}
+	buildHeights := !bytes.Equal(hash, tip.Hash)
+	buildTxs := txIndex && !chain.txIndex
+	buildAddresses := addressIndex && !chain.addressIndex
+
+	if !buildHeights && !buildTxs && !buildAddresses {
+		return nil
+	}
+
+	hashes := make([][]byte, tip.Height+1) // the hash of every block of the main chain by height
+	iter := chain.Iterator()
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		hashes[block.Height] = block.Hash
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	for start := 0; start < len(hashes); start += indexBatch {
+		end := start + indexBatch
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+
+		var blocks []*Block
+		for _, hash := range hashes[start:end] {
+			block, err := chain.GetBlock(hash)
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, &block)
+		}
+
+		err := chain.store.Update(func(txn Txn) error {
+			for _, block := range blocks {
+				if buildHeights {
+					if err := connectHeight(txn, block); err != nil {
+						return err
+					}
+				}
+				if buildTxs {
+					if err := connectTxs(txn, block); err != nil {
+						return err
+					}
+				}
+				if buildAddresses {
+					spent, err := chain.undoData(txn, block)
+					if err != nil {
+						return err
+					}
+					if err := connectAddresses(txn, block, spent); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !buildTxs && !buildAddresses {
+		return nil
+	}
+
+	err = chain.store.Update(func(txn Txn) error {
+		if buildTxs {
+			if err := txn.Set(txIndexKey, []byte{1}); err != nil {
+				return err
+			}
+		}
+		if buildAddresses {
+			return txn.Set(addrIndexKey, []byte{1})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	chain.txIndex = chain.txIndex || buildTxs
+	chain.addressIndex = chain.addressIndex || buildAddresses
+
+	return nil
+}
+
+// BlockChainForwardIterator : walks the main chain from a height up to the tip
+type BlockChainForwardIterator struct {
+	Height int // height of the block returned by the next call to Next
+	chain  *BlockChain
+}
+
+// ForwardIterator : iterator that starts at the block of the main chain at the height, 0 is the genesis block
+func (chain *BlockChain) ForwardIterator(height int) *BlockChainForwardIterator {
+	return &BlockChainForwardIterator{Height: height, chain: chain}
+}
+
+// Next : the block at the height of the iterator, nil after the tip
+func (iter *BlockChainForwardIterator) Next() (*Block, error) {
+	hash, err := iter.chain.BlockHashAt(iter.Height)
+	if errors.Is(err, ErrBlockNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, err := iter.chain.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	iter.Height++
+
+	return &block, nil
+}
@@ -0,0 +1,443 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/*
+	The mempool holds the transactions that are waiting to be mined. A transaction is only accepted
+	if every output it spends is in the UTXO set and no other pending transaction spends it, so any
+	group of pending transactions can be put in the same block. When a block is added the transactions
+	inside of it and the pending transactions that spend the same outputs are evicted. The pool is
+	stored in the database with the mempool prefix, so the pending transactions survive between runs.
+	The templates for new blocks take first the transactions that pay more fee for each byte.
+
+	Esp:
+
+	El mempool guarda las transacciones que están esperando a ser minadas. Una transacción solo se
+	acepta si cada output que gasta esta en el set UTXO y ninguna otra transacción pendiente lo gasta,
+	asi cualquier grupo de transacciones pendientes puede ir en el mismo bloque. Cuando se añade un
+	bloque se eliminan las transacciones que están dentro de el y las transacciones pendientes que
+	gastan los mismos outputs. El pool se guarda en la base de datos con el prefijo mempool, asi las
+	transacciones pendientes sobreviven entre ejecuciones. Las plantillas para bloques nuevos toman
+	primero las transacciones que pagan mas comisión por cada byte.
+*/
+
+// Mempool : transactions waiting to be mined on top of the chain
+type Mempool struct {
+	chain   *BlockChain
+	mu      sync.Mutex
+	txs     map[string]*mempoolEntry
+	spentBy map[string]string // outpoint to the ID of the pending transaction that spends it
+	seq     uint64
+}
+
+type mempoolEntry struct {
+	tx   *Transaction
+	fee  int
+	size int    // bytes of the serialized transaction
+	seq  uint64 // order of arrival
+}
+
+// feeRate : fee paid for each byte of the transaction
+func (entry *mempoolEntry) feeRate() float64 {
+	return float64(entry.fee) / float64(entry.size)
+}
+
+// outpoint : key of the output out of the transaction with the ID
+func outpoint(ID []byte, out int) string {
+	return fmt.Sprintf("%x:%d", ID, out)
+}
+
+// NewMempool : the pool with the pending transactions stored in the database, the ones that are no longer valid are dropped
+func NewMempool(chain *BlockChain) (*Mempool, error) {
+	pool := &Mempool{
+		chain:   chain,
+		txs:     make(map[string]*mempoolEntry),
+		spentBy: make(map[string]string),
+	}
+
+	var stored []*Transaction
+	err := chain.store.View(func(txn Txn) error {
+		it := txn.NewIterator(mempoolPrefix, false)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			v, err := it.Value()
+			if err != nil {
+				return err
+			}
+			tx, err := DeserializeTransaction(v)
+			if err != nil {
+				return err
+			}
+			stored = append(stored, &tx)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped [][]byte
+	for _, tx := range stored {
+		fee, err := pool.check(tx)
+		if isRuleError(err) { // confirmed or in conflict with a block added while the pool was not loaded
+			dropped = append(dropped, tx.ID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := pool.insert(tx, fee); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := pool.deleteStored(dropped); err != nil {
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// isRuleError : the error means that the transaction breaks the rules of the chain
+func isRuleError(err error) bool {
+	return errors.Is(err, ErrInvalidTx) || errors.Is(err, ErrDoubleSpend)
+}
+
+// Add : validate the transaction against the UTXO set and the pending transactions and add it to the pool
+func (pool *Mempool) Add(tx *Transaction) error {
+	if err := pool.add(tx); err != nil {
+		return err
+	}
+	pool.chain.Events.Publish(Event{Type: EventTxAccepted, Height: -1, TxIDs: [][]byte{tx.ID}, Addresses: txAddresses(tx)}) // without the lock of the pool
+
+	return nil
+}
+
+// add : Add without publishing the event
+func (pool *Mempool) add(tx *Transaction) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if _, ok := pool.txs[hex.EncodeToString(tx.ID)]; ok {
+		return fmt.Errorf("%w: %x", ErrKnownTx, tx.ID)
+	}
+
+	fee, err := pool.check(tx)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := tx.Serialize()
+	if err != nil {
+		return err
+	}
+	err = pool.chain.store.Update(func(txn Txn) error {
+		return txn.Set(append(append([]byte{}, mempoolPrefix...), tx.ID...), encoded)
+	})
+	if err != nil {
+		return err
+	}
+
+	return pool.insert(tx, fee)
+}
+
+// check : the rules that a pending transaction must follow, returns the fee of the transaction
+func (pool *Mempool) check(tx *Transaction) (int, error) {
+	if tx.IsCoinbase() { // coinbases are only valid inside of a block
+		return 0, fmt.Errorf("%w: coinbase %x outside of a block", ErrInvalidTx, tx.ID)
+	}
+	if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		return 0, fmt.Errorf("%w: %x has no inputs or no outputs", ErrInvalidTx, tx.ID)
+	}
+	if tx.Version != TxVersion { // the next block is created with the current versions
+		return 0, fmt.Errorf("%w: %x has version %d, expected %d", ErrInvalidTx, tx.ID, tx.Version, TxVersion)
+	}
+	if err := tx.checkID(); err != nil {
+		return 0, err
+	}
+
+	UTXOSet := UTXOSet{Blockchain: pool.chain}
+	spends := make(map[string]bool)
+	inputs := 0
+
+	for _, in := range tx.Inputs {
+		op := outpoint(in.ID, in.Out)
+		if spends[op] {
+			return 0, fmt.Errorf("%w: %x spends output %d of %x twice", ErrInvalidTx, tx.ID, in.Out, in.ID)
+		}
+		spends[op] = true
+
+		if other, ok := pool.spentBy[op]; ok {
+			return 0, fmt.Errorf("%w: output %d of %x is already spent by the pending transaction %s", ErrDoubleSpend, in.Out, in.ID, other)
+		}
+
+		out, found, err := UTXOSet.FindOutput(in.ID, in.Out)
+		if err != nil {
+			return 0, err
+		}
+		if !found {
+			return 0, fmt.Errorf("%w: output %d of %x is not unspent", ErrInvalidTx, in.Out, in.ID)
+		}
+		var ok bool
+		if inputs, ok = addValue(inputs, out.Value); !ok {
+			return 0, fmt.Errorf("%w: the inputs of %x overflow", ErrInvalidTx, tx.ID)
+		}
+	}
+
+	outputs, err := tx.outputsValue()
+	if err != nil {
+		return 0, err
+	}
+	if outputs > inputs { // a transaction can't create coins
+		return 0, fmt.Errorf("%w: %x spends %d but its outputs add up to %d", ErrInvalidTx, tx.ID, inputs, outputs)
+	}
+
+	valid, err := pool.chain.VerifyTransaction(tx)
+	if err != nil {
+		return 0, err
+	}
+	if !valid {
+		return 0, fmt.Errorf("%w: %x has an invalid signature", ErrInvalidTx, tx.ID)
+	}
+
+	return inputs - outputs, nil
+}
+
+// insert : add a checked transaction to the indexes of the pool
+func (pool *Mempool) insert(tx *Transaction, fee int) error {
+	txID := hex.EncodeToString(tx.ID)
+
+	encoded, err := tx.Serialize()
+	if err != nil {
+		return err
+	}
+
+	pool.seq++
+	pool.txs[txID] = &mempoolEntry{tx, fee, len(encoded), pool.seq}
+	for _, in := range tx.Inputs {
+		pool.spentBy[outpoint(in.ID, in.Out)] = txID
+	}
+
+	return nil
+}
+
+// remove : drop the transaction from the indexes of the pool
+func (pool *Mempool) remove(txID string) {
+	entry, ok := pool.txs[txID]
+	if !ok {
+		return
+	}
+
+	for _, in := range entry.tx.Inputs {
+		delete(pool.spentBy, outpoint(in.ID, in.Out))
+	}
+	delete(pool.txs, txID)
+}
+
+// deleteStored : delete the transactions with the IDs from the database
+func (pool *Mempool) deleteStored(IDs [][]byte) error {
+	if len(IDs) == 0 {
+		return nil
+	}
+
+	return pool.chain.store.Update(func(txn Txn) error {
+		for _, ID := range IDs {
+			if err := txn.Delete(append(append([]byte{}, mempoolPrefix...), ID...)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get : the pending transaction with the ID
+func (pool *Mempool) Get(ID []byte) (*Transaction, bool) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	entry, ok := pool.txs[hex.EncodeToString(ID)]
+	if !ok {
+		return nil, false
+	}
+
+	return entry.tx, true
+}
+
+// Count : number of pending transactions
+func (pool *Mempool) Count() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	return len(pool.txs)
+}
+
+// IsSpent : reports if a pending transaction spends the output out of the transaction with the ID
+func (pool *Mempool) IsSpent(ID []byte, out int) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	_, ok := pool.spentBy[outpoint(ID, out)]
+
+	return ok
+}
+
+// Fee : the fee paid by the pending transaction with the ID
+func (pool *Mempool) Fee(ID []byte) (int, bool) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	entry, ok := pool.txs[hex.EncodeToString(ID)]
+	if !ok {
+		return 0, false
+	}
+
+	return entry.fee, true
+}
+
+// Transactions : every pending transaction in the order they arrived
+func (pool *Mempool) Transactions() []*Transaction {
+	return pool.sorted(0, func(a, b *mempoolEntry) bool { return a.seq < b.seq })
+}
+
+// Template : the transactions for the next block, the ones with the highest fee rate first, at most max of them or all if max is 0
+func (pool *Mempool) Template(max int) []*Transaction {
+	return pool.sorted(max, func(a, b *mempoolEntry) bool {
+		if a.feeRate() != b.feeRate() {
+			return a.feeRate() > b.feeRate()
+		}
+		return a.seq < b.seq
+	})
+}
+
+// sorted : at most max pending transactions in the order of less
+func (pool *Mempool) sorted(max int, less func(a, b *mempoolEntry) bool) []*Transaction {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	entries := make([]*mempoolEntry, 0, len(pool.txs))
+	for _, entry := range pool.txs {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+
+	if max > 0 && len(entries) > max {
+		entries = entries[:max]
+	}
+
+	txs := make([]*Transaction, len(entries))
+	for i, entry := range entries {
+		txs[i] = entry.tx
+	}
+
+	return txs
+}
+
+// Remove : drop the pending transaction with the ID
+func (pool *Mempool) Remove(ID []byte) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.remove(hex.EncodeToString(ID))
+
+	return pool.deleteStored([][]byte{ID})
+}
+
+// RemoveBlock : evict the transactions confirmed by the block and the ones that spend the same outputs
+func (pool *Mempool) RemoveBlock(block *Block) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var evicted [][]byte
+	for _, tx := range block.Transactions {
+		txID := hex.EncodeToString(tx.ID)
+		if _, ok := pool.txs[txID]; ok { // confirmed
+			pool.remove(txID)
+			evicted = append(evicted, tx.ID)
+		}
+
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, in := range tx.Inputs {
+			conflict, ok := pool.spentBy[outpoint(in.ID, in.Out)]
+			if !ok {
+				continue
+			}
+			entry := pool.txs[conflict] // the block spent the output first
+			pool.remove(conflict)
+			evicted = append(evicted, entry.tx.ID)
+		}
+	}
+
+	return pool.deleteStored(evicted)
+}
+
+// Reorganize : update the pool after the main chain moved, the transactions of the disconnected blocks
+// that are still valid go back to the pool and the ones that spend outputs that are gone are evicted
+func (pool *Mempool) Reorganize(change *TipChange) error {
+	for _, block := range change.Connected {
+		if err := pool.RemoveBlock(block); err != nil {
+			return err
+		}
+	}
+
+	if len(change.Disconnected) == 0 {
+		return nil
+	}
+
+	if err := pool.recheck(); err != nil { // the outputs created by the disconnected blocks are no longer unspent
+		return err
+	}
+
+	for i := len(change.Disconnected) - 1; i >= 0; i-- { // from the oldest block up
+		for _, tx := range change.Disconnected[i].Transactions {
+			if tx.IsCoinbase() { // the reward of a disconnected block is lost
+				continue
+			}
+			err := pool.Add(tx)
+			if err != nil && !isRuleError(err) && !errors.Is(err, ErrKnownTx) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// recheck : evict the pending transactions that no longer follow the rules
+func (pool *Mempool) recheck() error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	entries := make(map[string]*mempoolEntry, len(pool.txs))
+	for txID, entry := range pool.txs {
+		entries[txID] = entry
+	}
+
+	var evicted [][]byte
+	for txID, entry := range entries {
+		pool.remove(txID) // so its own inputs don't look like a double spend
+		fee, err := pool.check(entry.tx)
+		if isRuleError(err) {
+			evicted = append(evicted, entry.tx.ID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := pool.insert(entry.tx, fee); err != nil {
+			return err
+		}
+		pool.txs[txID].seq = entry.seq // it keeps its place in the order of arrival
+	}
+
+	return pool.deleteStored(evicted)
+}
@@ -0,0 +1,237 @@
+package blockchain
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+	MemoryStore keeps the keys in a map that is never changed once it is shared: a transaction made
+	with Update writes to its own changes and, when it is committed, the changes are applied to a
+	copy of the map that replaces it. So every transaction reads a snapshot that doesn't change
+	while it runs, transactions can be nested like with badger, and committing costs a copy of the
+	whole map, which is fine for the chains of tests and simulations. Like badger, the store
+	remembers the commit that changed each key last and the transaction remembers the keys that it
+	read, a transaction that read a key changed by a commit made after it started fails with
+	ErrConflict instead of overwriting that commit with changes based on an old value.
+
+	Esp:
+
+	MemoryStore guarda las llaves en un mapa que nunca se cambia una vez que se comparte: una
+	transacción hecha con Update escribe en sus propios cambios y, cuando se confirma, los cambios
+	se aplican a una copia del mapa que lo reemplaza. Asi cada transacción lee una foto que no
+	cambia mientras corre, las transacciones se pueden anidar como con badger, y confirmar cuesta
+	una copia del mapa completo, lo que está bien para las cadenas de pruebas y simulaciones. Como
+	badger, el store recuerda la última confirmación que cambió cada llave y la transacción recuerda
+	las llaves que leyó, una transacción que leyó una llave cambiada por una confirmación hecha
+	después de que empezó falla con ErrConflict en vez de sobrescribir esa confirmación con cambios
+	basados en un valor viejo.
+*/
+
+// errReadOnly : a transaction made with View tried to write
+var errReadOnly = errors.New("the transaction is read only")
+
+// MemoryStore : Store in memory, the keys are lost when the program ends
+type MemoryStore struct {
+	mu       sync.Mutex
+	data     map[string][]byte // committed keys, replaced and never changed
+	version  uint64            // number of commits that changed keys
+	modified map[string]uint64 // version of the last commit that changed each key
+}
+
+// NewMemoryStore : empty store in memory
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte), modified: make(map[string]uint64)}
+}
+
+// snapshot : the committed keys and their version
+func (s *MemoryStore) snapshot() (map[string][]byte, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data, s.version
+}
+
+// View : run fn with the committed keys
+func (s *MemoryStore) View(fn func(txn Txn) error) error {
+	data, _ := s.snapshot()
+
+	return fn(newMemoryTxn(data, false))
+}
+
+// Update : run fn and apply its changes if it returns nil, ErrConflict when a transaction committed while
+// fn was running changed a key that fn read
+func (s *MemoryStore) Update(fn func(txn Txn) error) error {
+	data, start := s.snapshot()
+	txn := newMemoryTxn(data, true)
+	if err := fn(txn); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(txn.writes) == 0 && len(txn.deletes) == 0 { // like badger, a transaction that changes nothing never conflicts
+		return nil
+	}
+	for key := range txn.reads {
+		if s.modified[key] > start {
+			return ErrConflict
+		}
+	}
+
+	s.version++
+	data = make(map[string][]byte, len(s.data)+len(txn.writes))
+	for key, value := range s.data {
+		if !txn.deletes[key] {
+			data[key] = value
+		}
+	}
+	for key, value := range txn.writes {
+		data[key] = value
+		s.modified[key] = s.version
+	}
+	for key := range txn.deletes {
+		s.modified[key] = s.version
+	}
+	s.data = data
+
+	return nil
+}
+
+// Close : nothing to release, the keys are kept so the store can be opened again
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// memoryTxn : Txn of a MemoryStore, writes, deletes and reads are nil in a read only transaction
+type memoryTxn struct {
+	keyBlocks
+	data    map[string][]byte
+	writes  map[string][]byte
+	deletes map[string]bool
+	reads   map[string]bool // keys read from data, checked for conflicts when the transaction is committed
+}
+
+// newMemoryTxn : transaction that reads the keys of data
+func newMemoryTxn(data map[string][]byte, update bool) *memoryTxn {
+	t := &memoryTxn{data: data}
+	if update {
+		t.writes = make(map[string][]byte)
+		t.deletes = make(map[string]bool)
+		t.reads = make(map[string]bool)
+	}
+	t.keyBlocks = keyBlocks{t}
+
+	return t
+}
+
+// Get : the value of the key with the changes of the transaction
+func (t *memoryTxn) Get(key []byte) ([]byte, error) {
+	value, ok := t.writes[string(key)]
+	if !ok && !t.deletes[string(key)] {
+		t.read(string(key))
+		value, ok = t.data[string(key)]
+	}
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return append([]byte{}, value...), nil
+}
+
+// read : remember that the transaction depends on the committed value of the key
+func (t *memoryTxn) read(key string) {
+	if t.reads != nil {
+		t.reads[key] = true
+	}
+}
+
+// Set : store the value of the key when the transaction is committed
+func (t *memoryTxn) Set(key, value []byte) error {
+	if t.writes == nil {
+		return errReadOnly
+	}
+	delete(t.deletes, string(key))
+	t.writes[string(key)] = append([]byte{}, value...)
+
+	return nil
+}
+
+// Delete : remove the key when the transaction is committed
+func (t *memoryTxn) Delete(key []byte) error {
+	if t.writes == nil {
+		return errReadOnly
+	}
+	delete(t.writes, string(key))
+	t.deletes[string(key)] = true
+
+	return nil
+}
+
+// NewIterator : iterator over the keys of the prefix with the changes of the transaction
+func (t *memoryTxn) NewIterator(prefix []byte, keysOnly bool) Iterator {
+	var keys []string
+	for key := range t.data {
+		if strings.HasPrefix(key, string(prefix)) && !t.deletes[key] {
+			if _, ok := t.writes[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	for key := range t.writes {
+		if strings.HasPrefix(key, string(prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return &memoryIterator{txn: t, keys: keys}
+}
+
+// memoryIterator : Iterator over the keys of a memoryTxn sorted when it was created
+type memoryIterator struct {
+	txn  *memoryTxn
+	keys []string
+	pos  int
+}
+
+// Rewind : move to the first key of the prefix
+func (i *memoryIterator) Rewind() {
+	i.pos = 0
+}
+
+// Seek : move to the first key of the prefix that is equal or greater than the key
+func (i *memoryIterator) Seek(key []byte) {
+	i.pos = sort.SearchStrings(i.keys, string(key))
+}
+
+// Valid : the iterator is on a key of the prefix
+func (i *memoryIterator) Valid() bool {
+	return i.pos < len(i.keys)
+}
+
+// Next : move to the next key
+func (i *memoryIterator) Next() {
+	i.pos++
+}
+
+// Key : a copy of the current key
+func (i *memoryIterator) Key() []byte {
+	key := i.keys[i.pos]
+	if _, ok := i.txn.writes[key]; !ok {
+		i.txn.read(key)
+	}
+
+	return []byte(key)
+}
+
+// Value : a copy of the value of the current key
+func (i *memoryIterator) Value() ([]byte, error) {
+	return i.txn.Get([]byte(i.keys[i.pos]))
+}
+
+// Close : nothing to release
+func (i *memoryIterator) Close() {}
@@ -0,0 +1,300 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+)
+
+/*
+	A Merkle tree is a binary tree of hashes, the leaves are the hashes of the transaction IDs
+	and every other node is the hash of its two children concatenated, the root of the tree
+	represents all the transactions of a block. If a level has an odd number of nodes the last
+	one is paired with itself.
+	To prove that a transaction is inside of a block we don't need every transaction, only the
+	sibling of each node in the path from the leaf to the root, that's log2(n) hashes.
+
+	Since block version 2 a leaf hashes the byte 0 before the ID and an inner node hashes the byte
+	1 before its children, so the two children of a node can't be passed off as a transaction ID.
+	Pairing the last node with itself lets two different lists of transactions have the same root,
+	the list with the last transactions repeated, so a tree where two siblings are equal is
+	mutated and its block is rejected.
+
+	Esp:
+
+	Un árbol de Merkle es un árbol binario de hashes, las hojas son los hashes de los IDs de las
+	transacciones y cada uno de los otros nodos es el hash de sus dos hijos concatenados, la raíz del
+	árbol representa todas las transacciones de un bloque. Si un nivel tiene un numero impar de nodos
+	el ultimo se empareja consigo mismo.
+	Para probar que una transacción esta dentro de un bloque no necesitamos todas las transacciones,
+	solo el hermano de cada nodo en el camino desde la hoja hasta la raíz, eso son log2(n) hashes.
+
+	Desde la versión 2 de los bloques una hoja hashea el byte 0 antes del ID y un nodo interno hashea
+	el byte 1 antes de sus hijos, asi los dos hijos de un nodo no se pueden hacer pasar por el ID de
+	una transacción. Emparejar el ultimo nodo consigo mismo permite que dos listas distintas de
+	transacciones tengan la misma raíz, la lista con las ultimas transacciones repetidas, por eso un
+	árbol donde dos hermanos son iguales esta mutado y su bloque se rechaza.
+*/
+
+const (
+	merkleLeafPrefix = 0 // first byte hashed by a leaf since block version 2
+	merkleNodePrefix = 1 // first byte hashed by an inner node since block version 2
+)
+
+// MerkleTree : tree of hashes of the transactions of a block
+type MerkleTree struct {
+	RootNode *MerkleNode
+	levels   [][]*MerkleNode // every level of the tree, from the leaves to the root
+	version  int             // version of the block, it decides how the nodes are hashed
+}
+
+// MerkleNode : a node of the tree, leaves have no children
+type MerkleNode struct {
+	Left  *MerkleNode
+	Right *MerkleNode
+	Data  []byte
+}
+
+// MerkleProof : path of sibling hashes that links a transaction to the Merkle root of a block
+type MerkleProof struct {
+	BlockHash []byte
+	Header    BlockHeader // with the header the block hash and its proof of work can be checked offline
+	TxID      []byte
+	Index     int      // position of the transaction inside of the block, each bit says on which side the sibling is
+	Hashes    [][]byte // siblings from the leaf up to the root
+}
+
+// merkleLeaf : hash of a leaf with the data in a tree of the block version
+func merkleLeaf(data []byte, version int) []byte {
+	if version >= 2 {
+		data = append([]byte{merkleLeafPrefix}, data...)
+	}
+	hash := sha256.Sum256(data)
+
+	return hash[:]
+}
+
+// merkleParent : hash of an inner node with the hashes of its children in a tree of the block version
+func merkleParent(left, right []byte, version int) []byte {
+	var data []byte
+	if version >= 2 {
+		data = append(data, merkleNodePrefix)
+	}
+	data = append(append(data, left...), right...)
+	hash := sha256.Sum256(data)
+
+	return hash[:]
+}
+
+// NewMerkleNode : a leaf when there are no children, otherwise the hash of both children, hashed as the block version says
+func NewMerkleNode(left, right *MerkleNode, data []byte, version int) *MerkleNode {
+	node := MerkleNode{}
+
+	if left == nil && right == nil {
+		node.Data = merkleLeaf(data, version)
+	} else {
+		node.Data = merkleParent(left.Data, right.Data, version)
+	}
+
+	node.Left = left
+	node.Right = right
+
+	return &node
+}
+
+// NewMerkleTree : build the tree of a block of the version with a leaf for each piece of data
+func NewMerkleTree(data [][]byte, version int) *MerkleTree {
+	var level []*MerkleNode
+
+	for _, dat := range data {
+		level = append(level, NewMerkleNode(nil, nil, dat, version))
+	}
+	if len(level) == 0 { // a tree without data has an empty leaf as root
+		level = append(level, NewMerkleNode(nil, nil, []byte{}, version))
+	}
+
+	levels := [][]*MerkleNode{level}
+
+	for len(level) > 1 {
+		var newLevel []*MerkleNode
+
+		for i := 0; i < len(level); i += 2 {
+			left := level[i]
+			right := left // the last node of an odd level is paired with itself
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			newLevel = append(newLevel, NewMerkleNode(left, right, nil, version))
+		}
+
+		level = newLevel
+		levels = append(levels, level)
+	}
+
+	tree := MerkleTree{level[0], levels, version}
+
+	return &tree
+}
+
+// Mutated : two siblings of the tree are equal, the same root can be built from a list with repeated transactions
+func (t *MerkleTree) Mutated() bool {
+	for _, level := range t.levels {
+		for i := 0; i+1 < len(level); i += 2 {
+			if bytes.Equal(level[i].Data, level[i+1].Data) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Proof : sibling hashes from the leaf at the index up to the root
+func (t *MerkleTree) Proof(index int) ([][]byte, error) {
+	if index < 0 || index >= len(t.levels[0]) {
+		return nil, errors.New("leaf index out of range")
+	}
+
+	var hashes [][]byte
+
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		hashes = append(hashes, level[sibling].Data)
+		index /= 2
+	}
+
+	return hashes, nil
+}
+
+// MerkleProof : inclusion proof of the transaction with the ID inside of the block
+func (b *Block) MerkleProof(txID []byte) (*MerkleProof, error) {
+	for index, tx := range b.Transactions {
+		if !bytes.Equal(tx.ID, txID) {
+			continue
+		}
+
+		tree := b.MerkleTree()
+		hashes, err := tree.Proof(index)
+		if err != nil {
+			return nil, err
+		}
+
+		proof := MerkleProof{b.Hash, b.BlockHeader, txID, index, hashes}
+		return &proof, nil
+	}
+
+	return nil, errors.New("Transaction is not in the block")
+}
+
+// Verify : check that the path leads to the Merkle root and that the root belongs to a block mined with a
+// difficulty that the parameters of its chain allow, the difficulty of the proof alone proves no work
+func (p *MerkleProof) Verify(params ChainParams) bool {
+	if p.Header.Difficulty < params.MinDifficulty || p.Header.Difficulty > params.MaxDifficulty {
+		return false
+	}
+	if len(p.TxID) != sha256.Size || len(p.Hashes) > 31 || p.Index < 0 || p.Index >= 1<<len(p.Hashes) {
+		return false // the index must be a leaf of a tree as high as the path
+	}
+
+	version := p.Header.Version
+	hash := merkleLeaf(p.TxID, version)
+	index := p.Index
+
+	for _, sibling := range p.Hashes {
+		if len(sibling) != sha256.Size {
+			return false
+		}
+		if index%2 == 0 {
+			hash = merkleParent(hash, sibling, version)
+		} else {
+			hash = merkleParent(sibling, hash, version)
+		}
+		index /= 2
+	}
+
+	if !bytes.Equal(hash, p.Header.MerkleRoot) {
+		return false
+	}
+
+	blockHash := p.Header.Hash()
+
+	return bytes.Equal(blockHash, p.BlockHash) && meetsTarget(blockHash, p.Header.Difficulty)
+}
+
+type merkleProofJSON struct {
+	BlockHash  string   `json:"blockHash"`
+	Version    int      `json:"version"`
+	PrevHash   string   `json:"prevHash"`
+	MerkleRoot string   `json:"merkleRoot"`
+	Timestamp  int64    `json:"timestamp"`
+	Difficulty int      `json:"difficulty"`
+	Nonce      int      `json:"nonce"`
+	Height     int      `json:"height"`
+	TxID       string   `json:"txid"`
+	Index      int      `json:"index"`
+	Hashes     []string `json:"hashes"`
+}
+
+// MarshalJSON : hashes are written in hexadecimal
+func (p MerkleProof) MarshalJSON() ([]byte, error) {
+	hashes := make([]string, 0, len(p.Hashes))
+	for _, hash := range p.Hashes {
+		hashes = append(hashes, hex.EncodeToString(hash))
+	}
+
+	return json.Marshal(merkleProofJSON{
+		hex.EncodeToString(p.BlockHash),
+		p.Header.Version,
+		hex.EncodeToString(p.Header.PrevHash),
+		hex.EncodeToString(p.Header.MerkleRoot),
+		p.Header.Timestamp,
+		p.Header.Difficulty,
+		p.Header.Nonce,
+		p.Header.Height,
+		hex.EncodeToString(p.TxID),
+		p.Index,
+		hashes,
+	})
+}
+
+// UnmarshalJSON : read a proof written by MarshalJSON
+func (p *MerkleProof) UnmarshalJSON(data []byte) error {
+	var raw merkleProofJSON
+	var err error
+
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	decode := func(s string) []byte {
+		b, decodeErr := hex.DecodeString(s)
+		if decodeErr != nil && err == nil {
+			err = decodeErr
+		}
+		return b
+	}
+
+	p.BlockHash = decode(raw.BlockHash)
+	p.Header = BlockHeader{
+		Version:    raw.Version,
+		PrevHash:   decode(raw.PrevHash),
+		MerkleRoot: decode(raw.MerkleRoot),
+		Timestamp:  raw.Timestamp,
+		Difficulty: raw.Difficulty,
+		Nonce:      raw.Nonce,
+		Height:     raw.Height,
+	}
+	p.TxID = decode(raw.TxID)
+	p.Index = raw.Index
+	p.Hashes = nil
+	for _, hash := range raw.Hashes {
+		p.Hashes = append(p.Hashes, decode(hash))
+	}
+
+	return err
+}
@@ -0,0 +1,128 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testBlock : a mined block of the version with a transaction for each ID
+func testBlock(t *testing.T, version int, ids ...string) *Block {
+	t.Helper()
+
+	var txs []*Transaction
+	for _, id := range ids {
+		hash := sha256.Sum256([]byte(id))
+		txs = append(txs, &Transaction{ID: hash[:]})
+	}
+	block := newBlock(txs, []byte{}, 0, 4)
+	block.Version = version
+	block.MerkleRoot = block.HashTransactions()
+
+	nonce, hash, err := NewProof(block).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Nonce = nonce
+	block.Hash = hash
+
+	return block
+}
+
+func TestMerkleProofVerify(t *testing.T) {
+	for _, version := range []int{1, 2} {
+		block := testBlock(t, version, "a", "b", "c", "d", "e")
+		for _, tx := range block.Transactions {
+			proof, err := block.MerkleProof(tx.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proof.Verify(TestChainParams) {
+				t.Errorf("version %d: the proof of %x doesn't verify", version, tx.ID)
+			}
+		}
+	}
+}
+
+func TestMerkleProofRejectsBadPaths(t *testing.T) {
+	block := testBlock(t, BlockVersion, "a", "b", "c", "d")
+	proof, err := block.MerkleProof(block.Transactions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outOfRange := *proof
+	outOfRange.Index = proof.Index + 1<<len(proof.Hashes)
+	if outOfRange.Verify(TestChainParams) {
+		t.Error("an index beyond the leaves of the path verifies")
+	}
+
+	tree := block.MerkleTree()
+	inner := append(append([]byte{}, tree.levels[1][0].Data...), tree.levels[1][1].Data...)
+	forged := *proof
+	forged.TxID = inner // the two children of the root passed off as a transaction
+	forged.Index = 0
+	forged.Hashes = nil
+	if forged.Verify(TestChainParams) {
+		t.Error("a 64 byte ID built from two inner nodes verifies")
+	}
+}
+
+func TestMerkleProofNeedsWork(t *testing.T) {
+	txID := sha256.Sum256([]byte("a"))
+	for _, difficulty := range []int{0, -1, TestChainParams.MinDifficulty - 1, 300} {
+		header := BlockHeader{Version: BlockVersion, MerkleRoot: merkleLeaf(txID[:], BlockVersion), Difficulty: difficulty}
+		proof := MerkleProof{header.Hash(), header, txID[:], 0, nil} // a header that nobody mined
+		if proof.Verify(TestChainParams) {
+			t.Errorf("a header of difficulty %d verifies", difficulty)
+		}
+	}
+
+	block := testBlock(t, BlockVersion, "a", "b")
+	proof, err := block.MerkleProof(block.Transactions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Verify(DefaultChainParams) { // mined with the difficulty of the test network
+		t.Error("a block with less work than the network requires verifies")
+	}
+}
+
+func TestMerkleInnerNodesAreNotLeaves(t *testing.T) {
+	left, right := sha256.Sum256([]byte("a")), sha256.Sum256([]byte("b"))
+	tree := NewMerkleTree([][]byte{left[:], right[:]}, BlockVersion)
+	inner := append(append([]byte{}, tree.levels[0][0].Data...), tree.levels[0][1].Data...)
+
+	if root := NewMerkleTree([][]byte{inner}, BlockVersion).RootNode.Data; string(root) == string(tree.RootNode.Data) {
+		t.Error("a leaf with the children of the root hashes to the root")
+	}
+}
+
+func TestMutatedBlockIsRejected(t *testing.T) {
+	block := testBlock(t, BlockVersion, "a", "b", "c")
+	mutated := testBlock(t, BlockVersion, "a", "b", "c", "c")
+
+	if string(block.MerkleRoot) != string(mutated.MerkleRoot) {
+		t.Fatal("repeating the last transaction should give the same root")
+	}
+	if block.MerkleTree().Mutated() {
+		t.Error("a tree that pairs its last node with itself is mutated")
+	}
+	if !mutated.MerkleTree().Mutated() {
+		t.Error("a tree with two equal leaves is not mutated")
+	}
+
+	for i, tx := range mutated.Transactions { // valid IDs, only the tree is wrong
+		tx.Version = TxVersion
+		tx.Inputs = []TxInput{{[]byte{}, -1, nil, []byte{byte(i)}}}
+		if err := tx.SetID(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mutated.Transactions[3] = mutated.Transactions[2]
+	mutated.MerkleRoot = mutated.HashTransactions()
+	if err := mutated.checkTransactionIDs(); !errors.Is(err, ErrInvalidBlock) || !strings.Contains(err.Error(), "Merkle") {
+		t.Errorf("checkTransactionIDs of a mutated block: %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+	The migration upgrades a database of schema 0 to the current schema in place. Every key is
+	moved to its namespace and the blocks, transactions, outputs and undo data that are still
+	encoded with gob are rewritten with the binary format, in batches of Store transactions that
+	delete the old key and store the new one together. The keys that already have the new layout
+	are skipped, so a migration that was interrupted can be run again, and the schema version is
+	stored last. Before anything changes the directory of the database is copied next to it, and a
+	dry run reads every key and decodes every record without changing anything.
+
+	Esp:
+
+	La migración actualiza una base de datos del esquema 0 al esquema actual en el mismo lugar.
+	Cada llave se mueve a su espacio de nombres y los bloques, transacciones, outputs y datos de
+	deshacer que todavía están codificados con gob se reescriben con el formato binario, en partes
+	con transacciones del Store que borran la llave antigua y guardan la nueva juntas. Las llaves
+	que ya tienen el formato nuevo se saltan, asi una migración interrumpida se puede correr de
+	nuevo, y la versión del esquema se guarda al final. Antes de cambiar algo el directorio de la
+	base de datos se copia al lado de el, y una prueba en seco lee cada llave y decodifica cada
+	registro sin cambiar nada.
+*/
+
+// migrateBatch : keys moved in each transaction of a migration
+const migrateBatch = 1000
+
+// legacyKey : keys of schema 0 and how they are moved to the current schema
+type legacyKey struct {
+	kind     string                       // name of the keys in the report of the migration
+	prefix   []byte                       // prefix of the keys in schema 0
+	exact    bool                         // the prefix is the whole key
+	target   []byte                       // prefix that replaces the old one, nil when the keys are dropped
+	reencode func([]byte) ([]byte, error) // rewrites a record encoded with gob, nil when the values are kept
+}
+
+// legacyKeys : the keys of schema 0 that have a prefix, the blocks were stored by their bare hash
+var legacyKeys = []legacyKey{
+	{kind: "tip", prefix: []byte("lh"), exact: true, target: tipKey},
+	{kind: "work", prefix: []byte("work-"), target: workPrefix},
+	{kind: "undo", prefix: []byte("undo-"), target: undoPrefix, reencode: reencodeUndo},
+	{kind: "utxo", prefix: []byte("utxo-"), target: utxoPrefix, reencode: reencodeOutputs},
+	{kind: "mempool", prefix: []byte("mempool-"), target: mempoolPrefix, reencode: reencodeTx},
+	{kind: "height index", prefix: []byte("height-"), target: heightPrefix},
+	{kind: "tx index", prefix: []byte("tx-"), target: txPrefix},
+	{kind: "address index", prefix: []byte("addr-"), target: addrPrefix},
+	{kind: "tx index", prefix: []byte("txindex"), exact: true, target: txIndexKey},
+	{kind: "address index", prefix: []byte("addrindex"), exact: true, target: addrIndexKey},
+	{kind: "encoding mark", prefix: []byte("encoding"), exact: true}, // the schema version implies the binary format
+}
+
+// blockLegacyKey : how the blocks of schema 0 are moved
+var blockLegacyKey = legacyKey{kind: "block", target: blockPrefix, reencode: reencodeBlock}
+
+// currentPrefixes : namespaces of the keys of the current schema
+var currentPrefixes = [][]byte{blockPrefix, workPrefix, undoPrefix, utxoPrefix, mempoolPrefix, []byte("index/"), []byte("meta/")}
+
+// MigrationReport : what a migration changed, or would change in a dry run
+type MigrationReport struct {
+	From      int            // schema of the database before the migration
+	To        int            // schema of the database after the migration
+	Backup    string         // copy of the database made before migrating, empty in a dry run
+	Keys      map[string]int // keys moved to the current layout by kind
+	Reencoded int            // records rewritten from gob to the binary format
+	Dropped   int            // keys that the current schema doesn't use
+}
+
+// migration : a key of schema 0 and where it goes
+type migration struct {
+	key    []byte
+	target []byte // nil when the key is dropped
+	value  []byte
+}
+
+// findLegacyKey : how the key of schema 0 is moved, nil when it already has the current layout
+func findLegacyKey(key []byte) (*legacyKey, error) {
+	if len(key) == sha256.Size { // no other key of either schema has the size of a hash
+		return &blockLegacyKey, nil
+	}
+	for _, prefix := range currentPrefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return nil, nil
+		}
+	}
+	for i, legacy := range legacyKeys {
+		if legacy.exact && bytes.Equal(key, legacy.prefix) || !legacy.exact && bytes.HasPrefix(key, legacy.prefix) {
+			return &legacyKeys[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown key %q", key)
+}
+
+// MigrateDatabase : upgrade the database of the network to the current schema, copying it first.
+// A dry run only reports what would change, a store set in the options is migrated without a copy
+func MigrateDatabase(opts Options, dryRun bool) (*MigrationReport, error) {
+	if opts.Store == nil && !DBexists(opts.BlocksDir()) {
+		return nil, ErrNoChain
+	}
+
+	store, err := opts.openStore()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MigrationReport{To: SchemaVersion, Keys: make(map[string]int)}
+	err = store.View(func(txn Txn) error {
+		var err error
+		report.From, err = readSchema(txn)
+		return err
+	})
+	if err == nil && report.From > SchemaVersion {
+		err = schemaTooNew(report.From)
+	}
+	if err != nil || report.From == SchemaVersion {
+		store.Close()
+		return report, err
+	}
+
+	if !dryRun && opts.Store == nil { // badger keeps the directory locked, the copy is made while it is closed
+		if err := store.Close(); err != nil {
+			return nil, err
+		}
+		if report.Backup, err = BackupDatabase(opts); err != nil {
+			return nil, err
+		}
+		if store, err = opts.openStore(); err != nil {
+			return nil, err
+		}
+	}
+	defer store.Close()
+
+	if err := migrateKeys(store, report, dryRun); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	return report, store.Update(setSchema)
+}
+
+// migrateKeys : move the keys of schema 0 to the current layout, counting them in the report
+func migrateKeys(store Store, report *MigrationReport, dryRun bool) error {
+	var start []byte // first key of the next batch
+
+	for done := false; !done; {
+		var batch []migration
+
+		err := store.View(func(txn Txn) error {
+			it := txn.NewIterator(nil, false)
+			defer it.Close()
+
+			for it.Seek(start); it.Valid(); it.Next() {
+				key := it.Key()
+				if len(batch) == migrateBatch {
+					start = key
+					return nil
+				}
+
+				legacy, err := findLegacyKey(key)
+				if err != nil {
+					return err
+				}
+				if legacy == nil {
+					continue
+				}
+				if legacy.target == nil {
+					batch = append(batch, migration{key: key})
+					report.Dropped++
+					continue
+				}
+
+				value, err := it.Value()
+				if err != nil {
+					return err
+				}
+				if legacy.reencode != nil && isLegacy(value) {
+					if value, err = legacy.reencode(value); err != nil {
+						return fmt.Errorf("%s %x: %w", legacy.kind, key, err)
+					}
+					report.Reencoded++
+				}
+				target := append([]byte{}, legacy.target...)
+				if !legacy.exact {
+					target = append(target, key[len(legacy.prefix):]...)
+				}
+				batch = append(batch, migration{key: key, target: target, value: value})
+				report.Keys[legacy.kind]++
+			}
+			done = true
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			continue
+		}
+
+		err = store.Update(func(txn Txn) error {
+			for _, m := range batch {
+				if m.target != nil {
+					if err := txn.Set(m.target, m.value); err != nil {
+						return err
+					}
+				}
+				if err := txn.Delete(m.key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BackupDatabase : copy the directory of the closed database of the network next to it, the copy
+// is restored by replacing the directory with it
+func BackupDatabase(opts Options) (string, error) {
+	src := opts.BlocksDir()
+	dst := fmt.Sprintf("%s.backup-%s", src, time.Now().Format("20060102-150405"))
+
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf("the backup %s already exists", dst)
+	}
+	if err := os.MkdirAll(dst, 0700); err != nil {
+		return "", err
+	}
+
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		if !file.Mode().IsRegular() || file.Name() == "LOCK" {
+			continue
+		}
+		if err := copyFile(filepath.Join(src, file.Name()), filepath.Join(dst, file.Name())); err != nil {
+			return "", err
+		}
+	}
+
+	return dst, nil
+}
+
+// copyFile : copy the file at src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// reencodeBlock : block encoded with gob in the binary format
+func reencodeBlock(data []byte) ([]byte, error) {
+	block, err := Deserialize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return block.Serialize()
+}
+
+// reencodeTx : transaction encoded with gob in the binary format
+func reencodeTx(data []byte) ([]byte, error) {
+	tx, err := DeserializeTransaction(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.Serialize()
+}
+
+// reencodeOutputs : outputs encoded with gob in the binary format
+func reencodeOutputs(data []byte) ([]byte, error) {
+	outs, err := DeserializeOutputs(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return outs.Serialize()
+}
+
+// reencodeUndo : undo data encoded with gob in the binary format
+func reencodeUndo(data []byte) ([]byte, error) {
+	spent, err := decodeUndo(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeUndo(spent), nil
+}
@@ -0,0 +1,190 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// baselineChain : copy of the chain of tmp/blocks, stored with gob before the schemas existed
+func baselineChain(t *testing.T) Options {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	opts := Options{DataDir: dir, Network: "main"}
+	if err := os.MkdirAll(opts.BlocksDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join("..", "tmp", "blocks")
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if err := copyFile(filepath.Join(src, file.Name()), filepath.Join(opts.BlocksDir(), file.Name())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return opts
+}
+
+func TestMigrateBaselineChain(t *testing.T) {
+	opts := baselineChain(t)
+
+	report, err := MigrateDatabase(opts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.From != 0 || report.To != SchemaVersion || report.Reencoded != 2 || report.Keys["block"] != 2 {
+		t.Errorf("report %+v, expected 2 blocks re-encoded from schema 0", report)
+	}
+	if _, err := os.Stat(report.Backup); err != nil {
+		t.Errorf("backup: %v", err)
+	}
+
+	chain, err := ContinueBlockChain(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	expected := []struct {
+		hash  string
+		nonce int
+	}{
+		{"000ecbf1c4b464eaa2316d3e38332b58bbeb7936a214a9b90565d2ca2afa32c7", 14067},
+		{"0004accbf89fa98912309847d0758d57e4db287cc6db8dc875ba47180b82eec9", 4485},
+	}
+	iter := chain.Iterator()
+	for i, exp := range expected {
+		block, err := iter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(block.Hash) != exp.hash || block.Nonce != exp.nonce {
+			t.Errorf("block %d is %x with nonce %d, expected %s with nonce %d", i, block.Hash, block.Nonce, exp.hash, exp.nonce)
+		}
+		if i+1 < len(expected) && hex.EncodeToString(block.PrevHash) != expected[i+1].hash {
+			t.Errorf("block %d points to %x, expected %s", i, block.PrevHash, expected[i+1].hash)
+		}
+		if len(block.Transactions) != 1 || block.Transactions[0].Version != 1 {
+			t.Errorf("block %d doesn't have its coinbase of version 1", i)
+		}
+	}
+	if len(iter.CurrentHash) != 0 {
+		t.Errorf("the genesis block points to %x", iter.CurrentHash)
+	}
+
+	err = chain.store.View(func(txn Txn) error {
+		it := txn.NewIterator(blockPrefix, false)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			data, err := it.Value()
+			if err != nil {
+				return err
+			}
+			if isLegacy(data) {
+				t.Errorf("the block %x is still encoded with gob", it.Key())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeserializeLegacyBlocks(t *testing.T) {
+	coinbase := &Transaction{ID: []byte("id"), Inputs: []TxInput{{[]byte{}, -1, nil, []byte("data")}}}
+	header := BlockHeader{Version: 2, PrevHash: []byte("prev"), MerkleRoot: []byte("root"), Timestamp: 10, Difficulty: 8, Nonce: 7, Height: 3}
+
+	for _, test := range []struct {
+		name   string
+		value  interface{}
+		header BlockHeader
+	}{
+		{"without header", struct { // the layout of the first blocks
+			Hash         []byte
+			Transactions []*Transaction
+			PrevHash     []byte
+			Nonce        int
+		}{[]byte("hash"), []*Transaction{coinbase}, []byte("prev"), 7}, BlockHeader{PrevHash: []byte("prev"), Nonce: 7}},
+		{"with header", Block{header, []byte("hash"), []*Transaction{coinbase}}, header},
+	} {
+		var data bytes.Buffer
+		if err := gob.NewEncoder(&data).Encode(test.value); err != nil {
+			t.Fatal(err)
+		}
+
+		block, err := Deserialize(data.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		h := block.BlockHeader
+		if h.Version != test.header.Version || !bytes.Equal(h.PrevHash, test.header.PrevHash) || !bytes.Equal(h.MerkleRoot, test.header.MerkleRoot) ||
+			h.Timestamp != test.header.Timestamp || h.Difficulty != test.header.Difficulty || h.Nonce != test.header.Nonce || h.Height != test.header.Height {
+			t.Errorf("%s: header %+v, expected %+v", test.name, h, test.header)
+		}
+		if !bytes.Equal(block.Hash, []byte("hash")) || len(block.Transactions) != 1 || block.Transactions[0].Version != 1 {
+			t.Errorf("%s: hash %q and transactions %v", test.name, block.Hash, block.Transactions)
+		}
+	}
+}
+
+func TestTransactionVersions(t *testing.T) {
+	tx, err := CoinbaseTx("1BoatSLRHtKNngkdXEeobR76b53LETtpyT", "data", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Version != TxVersion {
+		t.Fatalf("version %d, expected %d", tx.Version, TxVersion)
+	}
+	if err := tx.checkID(); err != nil {
+		t.Fatal(err)
+	}
+
+	var e encoder // the body without the version byte of the records and with an empty ID
+	e.writeTx(&Transaction{tx.Version, nil, tx.Inputs, tx.Outputs})
+	if hash := sha256.Sum256(e.buffer.Bytes()); !bytes.Equal(hash[:], tx.ID) {
+		t.Error("the ID is not the hash of the binary format")
+	}
+
+	old := *tx // a transaction of version 1 keeps the ID of hashData
+	old.Version = 1
+	if err := old.SetID(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(old.ID, tx.ID) {
+		t.Error("the versions 1 and 2 give the same ID")
+	}
+
+	var body encoder
+	body.writeTx(&old)
+	record := append([]byte{1}, body.buffer.Bytes()[1:]...) // the records of the version 1 don't have the version of the transaction, a single byte
+	decoded, err := DeserializeTransaction(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != 1 || decoded.checkID() != nil {
+		t.Errorf("a record of the version 1 decoded with version %d: %v", decoded.Version, decoded.checkID())
+	}
+
+	for _, version := range []int{0, TxVersion + 1} {
+		unknown := *tx
+		unknown.Version = version
+		if err := unknown.checkID(); err == nil {
+			t.Errorf("a transaction of version %d has a valid ID", version)
+		}
+	}
+}
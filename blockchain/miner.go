@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/sha256"
+	"math"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	Mining is embarrassingly parallel, every nonce can be tried independently, so the nonce space is
+	split across several goroutines: with N workers the worker i tries the nonces i, i+N, i+2N...
+	The first worker that finds a valid hash cancels the others. The context allows the caller to
+	abort the whole job, for example when another node sends a block for the same height.
+
+	Esp:
+
+	Minar es trivialmente paralelizable, cada nonce se puede probar de forma independiente, asi que el
+	espacio de nonces se reparte entre varias goroutines: con N workers el worker i prueba los nonces
+	i, i+N, i+2N...
+	El primer worker que encuentra un hash valido cancela a los demás. El contexto permite al que llama
+	abortar todo el trabajo, por ejemplo cuando otro nodo envía un bloque para la misma altura.
+*/
+
+const hashBatch = 1024 // hashes computed by a worker between two checks of the context
+
+// MinerStats : progress of a mining job
+type MinerStats struct {
+	Hashes   uint64        // hashes computed by all the workers
+	Elapsed  time.Duration // time since the job started
+	HashRate float64       // hashes per second
+	Done     bool          // true in the last report of the job
+}
+
+// MinerOptions : how a block is mined
+type MinerOptions struct {
+	Threads       int              // number of worker goroutines, one per CPU when it's 0
+	StatsInterval time.Duration    // time between two calls to OnStats, one second when it's 0
+	OnStats       func(MinerStats) // receives the progress of the job, nothing is reported when it's nil
+}
+
+// Mine : search a nonce that makes the hash of the block lower than the target
+func (pow *ProofOfWork) Mine(ctx context.Context, opts MinerOptions) (int, []byte, error) {
+	threads := opts.Threads
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+	interval := opts.StatsInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	type solution struct {
+		nonce int
+		hash  []byte
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	found := make(chan solution, threads) // each worker sends at most one solution, so it never blocks
+	done := make(chan struct{})
+	var hashes uint64
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func(nonce int) {
+			defer wg.Done()
+
+			var intHash big.Int
+			var count uint64
+
+			for ; nonce >= 0 && nonce < math.MaxInt64; nonce += threads { // nonce < 0 when it overflows
+				if count == hashBatch {
+					atomic.AddUint64(&hashes, count)
+					count = 0
+					if jobCtx.Err() != nil {
+						return
+					}
+				}
+
+				hash := sha256.Sum256(pow.InitData(nonce))
+				count++
+
+				intHash.SetBytes(hash[:])
+				if intHash.Cmp(pow.Target) == -1 {
+					atomic.AddUint64(&hashes, count)
+					found <- solution{nonce, hash[:]}
+					cancel()
+					return
+				}
+			}
+			atomic.AddUint64(&hashes, count)
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	report := func(last bool) {
+		if opts.OnStats == nil {
+			return
+		}
+		elapsed := time.Since(start)
+		total := atomic.LoadUint64(&hashes)
+		opts.OnStats(MinerStats{total, elapsed, float64(total) / elapsed.Seconds(), last})
+	}
+
+	var tick <-chan time.Time
+	if opts.OnStats != nil {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+Wait:
+	for {
+		select {
+		case <-done:
+			break Wait
+		case <-tick:
+			report(false)
+		}
+	}
+	report(true)
+	close(found)
+
+	best := solution{-1, nil}
+	for sol := range found { // more than one worker can find a hash before being cancelled, keep the lowest nonce
+		if best.hash == nil || sol.nonce < best.nonce {
+			best = sol
+		}
+	}
+	if best.hash != nil {
+		return best.nonce, best.hash, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	return 0, nil, ErrNonceSpaceExhausted
+}
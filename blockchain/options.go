@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger"
+)
+
+/*
+	Every network has its own directory inside of the data directory, so the chains of different
+	networks never share a database. The main network uses the data directory itself, that is
+	where the chains created before networks existed are stored.
+
+	./tmp                  data directory
+	./tmp/blocks           badger database of the main network
+	./tmp/wallets.data     wallets of the main network
+	./tmp/test/blocks      badger database of the test network
+	./tmp/test/wallets.data
+
+	Esp:
+
+	Cada red tiene su propio directorio dentro del directorio de datos, asi las cadenas de redes
+	distintas nunca comparten una base de datos. La red principal usa el mismo directorio de datos,
+	ahí es donde están las cadenas creadas antes de que existieran las redes.
+*/
+
+const (
+	// DefaultDataDir : data directory used when Options.DataDir is empty
+	DefaultDataDir = "./tmp"
+	// DefaultNetwork : network used when Options.Network is empty
+	DefaultNetwork = "main"
+)
+
+// Options : where a chain is stored and which network it belongs to
+type Options struct {
+	DataDir      string // directory with the data of every network
+	Network      string // name of the network, one of the keys of Networks
+	TxIndex      bool   // index the transactions of the main chain by ID, once built the index is always kept
+	AddressIndex bool   // index the transactions of the main chain by address, once built the index is always kept
+	Badger       BadgerOptions
+	Store        Store // where the chain is kept, the badger database of BlocksDir when nil
+}
+
+// BadgerOptions : tuning of the badger database, zero values keep the badger defaults
+type BadgerOptions struct {
+	SyncWrites       bool  // wait until every write reaches the disk
+	Truncate         bool  // truncate a corrupted value log instead of failing to open the database
+	ValueLogFileSize int64 // max size in bytes of each value log file
+	MaxTableSize     int64 // max size in bytes of each table
+	NumMemtables     int   // tables kept in memory before they are flushed
+	NumCompactors    int   // goroutines that compact the tables
+}
+
+// Networks : chain parameters of every known network
+var Networks = map[string]ChainParams{
+	"main": DefaultChainParams,
+	"test": TestChainParams,
+}
+
+// withDefaults : the options with the empty fields filled with their default values
+func (opts Options) withDefaults() Options {
+	if opts.DataDir == "" {
+		opts.DataDir = DefaultDataDir
+	}
+	if opts.Network == "" {
+		opts.Network = DefaultNetwork
+	}
+
+	return opts
+}
+
+// Params : chain parameters of the network
+func (opts Options) Params() (ChainParams, error) {
+	opts = opts.withDefaults()
+
+	params, ok := Networks[opts.Network]
+	if !ok {
+		return ChainParams{}, fmt.Errorf("%w %q", ErrUnknownNetwork, opts.Network)
+	}
+
+	return params, nil
+}
+
+// NetworkDir : directory with the data of the network
+func (opts Options) NetworkDir() string {
+	opts = opts.withDefaults()
+
+	if opts.Network == DefaultNetwork {
+		return opts.DataDir
+	}
+
+	return filepath.Join(opts.DataDir, opts.Network)
+}
+
+// BlocksDir : directory of the badger database of the network
+func (opts Options) BlocksDir() string {
+	return filepath.Join(opts.NetworkDir(), "blocks")
+}
+
+// WalletFile : file with the wallets of the network
+func (opts Options) WalletFile() string {
+	return filepath.Join(opts.NetworkDir(), "wallets.data")
+}
+
+// CookieFile : file with the token that the clients of the JSON-RPC server of the network send to authenticate
+func (opts Options) CookieFile() string {
+	return filepath.Join(opts.NetworkDir(), ".cookie")
+}
+
+// openStore : the store of the options or the badger database of the network
+func (opts Options) openStore() (Store, error) {
+	if opts.Store != nil {
+		return opts.Store, nil
+	}
+
+	return OpenBadgerStore(opts)
+}
+
+// badgerOptions : badger options for the database of the network
+func (opts Options) badgerOptions() badger.Options {
+	path := opts.BlocksDir()
+
+	bOpts := badger.DefaultOptions
+	bOpts.Dir = path
+	bOpts.ValueDir = path
+	bOpts.SyncWrites = opts.Badger.SyncWrites
+	bOpts.Truncate = opts.Badger.Truncate
+	if opts.Badger.ValueLogFileSize > 0 {
+		bOpts.ValueLogFileSize = opts.Badger.ValueLogFileSize
+	}
+	if opts.Badger.MaxTableSize > 0 {
+		bOpts.MaxTableSize = opts.Badger.MaxTableSize
+	}
+	if opts.Badger.NumMemtables > 0 {
+		bOpts.NumMemtables = opts.Badger.NumMemtables
+	}
+	if opts.Badger.NumCompactors > 0 {
+		bOpts.NumCompactors = opts.Badger.NumCompactors
+	}
+
+	return bOpts
+}
@@ -0,0 +1,125 @@
+package blockchain
+
+/*
+	The consensus rules that can be tuned are grouped in the chain parameters, every node of a
+	network must use the same parameters, otherwise they won't agree on which blocks are valid.
+
+	Esp:
+
+	Las reglas de consenso que se pueden ajustar están agrupadas en los parámetros de la cadena,
+	todos los nodos de una red deben usar los mismos parámetros, de lo contrario no se pondrán de
+	acuerdo en que bloques son validos.
+*/
+
+// ChainParams : consensus parameters of a chain
+type ChainParams struct {
+	GenesisData       string // arbitrary data of the coinbase of the genesis block
+	InitialDifficulty int    // difficulty of the genesis block and of every block until the first retarget
+	MinDifficulty     int    // the difficulty never goes below this value
+	MaxDifficulty     int    // the difficulty never goes above this value
+	TargetBlockTime   int64  // seconds that should pass between two blocks
+	RetargetInterval  int    // the difficulty is recomputed every RetargetInterval blocks
+	MaxRetargetStep   int    // max number of bits that a single retarget can add or remove
+	InitialSubsidy    int    // coins created by the coinbase of the blocks before the first halving
+	HalvingInterval   int    // the subsidy is halved every HalvingInterval blocks, 0 means it never changes
+	MaxSupply         int    // the coinbases never create more coins than this in total, 0 means no limit
+}
+
+// DefaultChainParams : parameters used when a chain is created or opened
+var DefaultChainParams = ChainParams{
+	GenesisData:       "First Transaction from Genesis",
+	InitialDifficulty: 12,
+	MinDifficulty:     8,
+	MaxDifficulty:     64,
+	TargetBlockTime:   10,
+	RetargetInterval:  10,
+	MaxRetargetStep:   2,
+	InitialSubsidy:    100,
+	HalvingInterval:   1000,
+	MaxSupply:         200000,
+}
+
+// TestChainParams : parameters of the test network, blocks are cheaper and come faster
+var TestChainParams = ChainParams{
+	GenesisData:       "First Transaction from Test Genesis",
+	InitialDifficulty: 8,
+	MinDifficulty:     4,
+	MaxDifficulty:     64,
+	TargetBlockTime:   5,
+	RetargetInterval:  10,
+	MaxRetargetStep:   2,
+	InitialSubsidy:    100,
+	HalvingInterval:   20,
+	MaxSupply:         3000,
+}
+
+/*
+	The subsidy starts at InitialSubsidy and is halved every HalvingInterval blocks, so the number
+	of coins grows slower and slower. The coins created until a height can't go above MaxSupply,
+	the block that would go above it only gets what is left, and after it the subsidy is zero and
+	the miners only get the fees.
+
+	Esp:
+
+	El subsidio empieza en InitialSubsidy y se reduce a la mitad cada HalvingInterval bloques, asi
+	la cantidad de monedas crece cada vez mas lento. Las monedas creadas hasta una altura no pueden
+	superar MaxSupply, el bloque que lo superaría solo recibe lo que queda, y después de el el
+	subsidio es cero y los mineros solo reciben las comisiones.
+*/
+
+// scheduledSupply : coins created by the blocks from the genesis to the height following only the halvings
+func (params ChainParams) scheduledSupply(height int) int {
+	if height < 0 {
+		return 0
+	}
+	if params.HalvingInterval <= 0 {
+		return (height + 1) * params.InitialSubsidy
+	}
+
+	supply := 0
+	blocks := height + 1
+	for era := 0; blocks > 0; era++ {
+		subsidy := params.subsidyOfEra(era)
+		if subsidy == 0 {
+			break
+		}
+		inEra := params.HalvingInterval
+		if blocks < inEra {
+			inEra = blocks
+		}
+		supply += inEra * subsidy
+		blocks -= inEra
+	}
+
+	return supply
+}
+
+// subsidyOfEra : subsidy after era halvings
+func (params ChainParams) subsidyOfEra(era int) int {
+	if era >= 63 {
+		return 0
+	}
+	return params.InitialSubsidy >> uint(era)
+}
+
+// Supply : coins created by the coinbases of the blocks from the genesis to the height
+func (params ChainParams) Supply(height int) int {
+	supply := params.scheduledSupply(height)
+	if params.MaxSupply > 0 && supply > params.MaxSupply {
+		return params.MaxSupply
+	}
+	return supply
+}
+
+// Subsidy : coins created by the coinbase of the block at the height, the miner also gets the fees of the block
+func (params ChainParams) Subsidy(height int) int {
+	return params.Supply(height) - params.Supply(height-1)
+}
+
+// NextHalving : height of the first block after height with a smaller subsidy, 0 if the subsidy never changes
+func (params ChainParams) NextHalving(height int) int {
+	if params.HalvingInterval <= 0 {
+		return 0
+	}
+	return (height/params.HalvingInterval + 1) * params.HalvingInterval
+}
@@ -2,11 +2,9 @@ package blockchain
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/binary"
-	"fmt"
-	"log"
-	"math"
 	"math/big"
 )
 
@@ -80,11 +78,8 @@ ajustado sobre el tiempo, eso es esencialmente la dificultad, que la dificultad
 significa que progresivamente deben haber mas 0s en el principio del hash para que este sea valido
 */
 
-// Difficulty : stay static
-const Difficulty = 12
-
-/* In our implementation of this algorithm our difficulty is going to stay
-static.
+/* In the first version of this implementation the difficulty was a constant, now every block stores
+its own difficulty and the chain recomputes it every ChainParams.RetargetInterval blocks.
 In a real block chain generally you would have an algorithm that with slowly increment this difficulty
 over a large period of time, the main reason you want to do this is to account for the increasing number
 of miners of the network and also account for the increase in computation power of computers in general, because
@@ -92,8 +87,15 @@ we wanna make the time to mine a block stay the same and we also want to have th
 means that you need to have a certain amount of computational power running the proof of work algorithm to
 produce blocks at that rate but also keep the time to sign a block down
 
+Retargeting:
+The time that the last blocks took is compared with the time that they should have taken
+(ChainParams.TargetBlockTime for each block), each bit of difficulty doubles the work, so for every time
+that the blocks were twice as fast the difficulty goes up one bit, and for every time that they were twice as
+slow it goes down one bit. A single retarget can't move the difficulty more than ChainParams.MaxRetargetStep bits.
+
 Esp:
-En nuestra implementación de este algoritmo la dificultad se va a mantener estática.
+En la primera versión de esta implementación la dificultad era una constante, ahora cada bloque guarda
+su propia dificultad y la cadena la recalcula cada ChainParams.RetargetInterval bloques.
 En una blockchain real generalmente deberías de tener un algoritmo el cual vaya lentamente
 incrementando la dificultad durante un largo periodo de tiempo, la razon principal de esto es que tienes que
 tener en cuenta el numero creciente de mineros de la red y ademas tener en cuenta el incremento de poder
@@ -102,68 +104,107 @@ la taza de creación de estos, esto significa que necesitas tener cierta cantida
 algoritmo de proof of work para producir bloques a la misma taza pero ademas mantener igual el tiempo que toma
 firmar un bloque
 
+Reajuste:
+El tiempo que tomaron los últimos bloques se compara con el tiempo que deberían haber tomado
+(ChainParams.TargetBlockTime por cada bloque), cada bit de dificultad duplica el trabajo, asi que por cada vez
+que los bloques fueron el doble de rápidos la dificultad sube un bit, y por cada vez que fueron el doble de
+lentos baja un bit. Un solo reajuste no puede mover la dificultad mas de ChainParams.MaxRetargetStep bits.
+
 */
 
 type ProofOfWork struct {
-	Block  *Block
-	Target *big.Int
+	Block      *Block
+	Target     *big.Int
+	merkleRoot []byte // the transactions don't change while mining, so their root is computed only once
 }
 
 func NewProof(b *Block) *ProofOfWork {
-	target := big.NewInt(1)
-	target.Lsh(target, uint(256-Difficulty))
-
-	pow := &ProofOfWork{b, target}
+	pow := &ProofOfWork{b, target(b.Difficulty), b.HashTransactions()}
 
 	return pow
 }
 
+// InitData : the header of the block with the nonce, the Merkle root is computed from the transactions
 func (pow *ProofOfWork) InitData(nonce int) []byte {
+	header := pow.Block.BlockHeader
+	header.MerkleRoot = pow.merkleRoot
+	header.Nonce = nonce
+
+	return header.Data()
+}
+
+// Data : the bytes of the header that are hashed, since block version 3 the header in the binary format
+func (h BlockHeader) Data() []byte {
+	if h.Version >= 3 {
+		var e encoder // without the version byte of the records
+		e.writeHeader(h)
+		return e.buffer.Bytes()
+	}
+
 	data := bytes.Join(
 		[][]byte{
-			pow.Block.PrevHash,
-			pow.Block.HashTransactions(),
-			ToHex(int64(nonce)),
-			ToHex(int64(Difficulty)),
+			ToHex(int64(h.Version)),
+			h.PrevHash,
+			h.MerkleRoot,
+			ToHex(h.Timestamp),
+			ToHex(int64(h.Difficulty)),
+			ToHex(int64(h.Nonce)),
+			ToHex(int64(h.Height)),
 		},
 		[]byte{},
 	)
 	return data
 }
 
-func (pow *ProofOfWork) Run() (int, []byte) {
-	var intHash big.Int
-	var hash [32]byte
-
-	nonce := 0
+// Hash : hash of the header, the hash of the block that it belongs to
+func (h BlockHeader) Hash() []byte {
+	hash := sha256.Sum256(h.Data())
 
-	for nonce < math.MaxInt64 {
-		data := pow.InitData(nonce)
-		hash = sha256.Sum256(data)
+	return hash[:]
+}
 
-		fmt.Printf("\r%x", hash)
-		intHash.SetBytes(hash[:])
+// hashBits : bits of a hash, no hash meets the target of a higher difficulty
+const hashBits = sha256.Size * 8
 
-		if intHash.Cmp(pow.Target) == -1 {
-			break
-		} else {
-			nonce++
-		}
+// target : a hash is valid when it is lower than 2^(256 - difficulty), a difficulty outside 0..256 gets
+// the target 0 that no hash meets
+func target(difficulty int) *big.Int {
+	if difficulty < 0 || difficulty > hashBits {
+		return new(big.Int)
 	}
-	fmt.Println()
 
-	return nonce, hash[:]
+	target := big.NewInt(1)
+	target.Lsh(target, uint(256-difficulty))
+
+	return target
+}
+
+// meetsTarget : check if the hash is lower than the target of the difficulty
+func meetsTarget(hash []byte, difficulty int) bool {
+	var intHash big.Int
+	intHash.SetBytes(hash)
+
+	return intHash.Cmp(target(difficulty)) == -1
+}
+
+// Run : mine the block with every CPU and without reporting the progress
+func (pow *ProofOfWork) Run() (int, []byte, error) {
+	return pow.Mine(context.Background(), MinerOptions{})
 }
 
 func (pow *ProofOfWork) Validate() bool {
 	var intHash big.Int
 
+	if !bytes.Equal(pow.Block.MerkleRoot, pow.merkleRoot) { // the header must commit to the transactions of the block
+		return false
+	}
+
 	data := pow.InitData(pow.Block.Nonce)
 
 	hash := sha256.Sum256(data)
 	intHash.SetBytes(hash[:])
 
-	return intHash.Cmp(pow.Target) == -1
+	return intHash.Cmp(pow.Target) == -1 && bytes.Equal(hash[:], pow.Block.Hash)
 }
 
 /* what makes this proof of work algorithm very secure for a blockchain is this idea that if you
@@ -183,11 +224,33 @@ contenida en la blockchain de ser moderada por una sola entidad o por un conjunt
 */
 
 func ToHex(num int64) []byte {
-	buff := new(bytes.Buffer)
-	err := binary.Write(buff, binary.BigEndian, num)
-	if err != nil {
-		log.Panic(err)
+	buff := make([]byte, 8)
+	binary.BigEndian.PutUint64(buff, uint64(num))
+
+	return buff
+}
+
+// retarget : new difficulty after blocks that took actual seconds when they should have taken expected seconds
+func (params ChainParams) retarget(difficulty int, actual, expected int64) int {
+	if actual < 1 {
+		actual = 1
+	}
+
+	step := 0
+	for span := actual; span*2 <= expected && step < params.MaxRetargetStep; span *= 2 { // blocks were too fast
+		step++
+	}
+	for span := actual; span >= expected*2 && step > -params.MaxRetargetStep; span /= 2 { // blocks were too slow
+		step--
+	}
+
+	difficulty += step
+	if difficulty < params.MinDifficulty {
+		difficulty = params.MinDifficulty
+	}
+	if difficulty > params.MaxDifficulty {
+		difficulty = params.MaxDifficulty
 	}
 
-	return buff.Bytes()
+	return difficulty
 }
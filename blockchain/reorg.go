@@ -0,0 +1,399 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+/*
+	Every valid block is stored by its hash, even when it is not part of the main chain, together
+	with the work accumulated by the branch that ends in it. The work of a block is the number of
+	hashes that its difficulty needs on average, 2^difficulty, so the main chain is the branch with
+	the most proof of work and not the longest one. When a block makes a side branch heavier than
+	the main chain the node reorganizes: the blocks of the old branch are disconnected from the tip
+	down to the fork point, putting back the outputs they spent with the undo data stored for each
+	block, and the blocks of the new branch are connected from the fork point up. Everything is done
+	in a single badger transaction, if a block of the new branch breaks the rules nothing changes.
+	Blocks whose previous block is unknown are orphans, they are held in memory until it arrives.
+
+	Esp:
+
+	Cada bloque valido se guarda por su hash, aunque no sea parte de la cadena principal, junto con
+	el trabajo acumulado por la rama que termina en el. El trabajo de un bloque es el numero de
+	hashes que su dificultad necesita en promedio, 2^dificultad, asi la cadena principal es la rama
+	con mas prueba de trabajo y no la mas larga. Cuando un bloque hace que una rama lateral pese mas
+	que la cadena principal el nodo se reorganiza: los bloques de la rama antigua se desconectan
+	desde la punta hasta el punto de bifurcación, devolviendo los outputs que gastaron con los datos
+	de deshacer guardados para cada bloque, y los bloques de la rama nueva se conectan desde el punto
+	de bifurcación hacia arriba. Todo se hace en una sola transacción de badger, si un bloque de la
+	rama nueva rompe las reglas nada cambia. Los bloques cuyo bloque anterior es desconocido son
+	huérfanos, se mantienen en memoria hasta que este llegue.
+*/
+
+// maxOrphans : max number of orphan blocks held in memory
+const maxOrphans = 100
+
+// TipChange : how the main chain moved after a block was accepted
+type TipChange struct {
+	Disconnected []*Block // blocks removed from the main chain, from the old tip down to the fork point
+	Connected    []*Block // blocks added to the main chain, from the fork point up to the new tip
+}
+
+// spentOutput : an output spent by a block, it's unspent again when the block is disconnected
+type spentOutput struct {
+	ID     []byte
+	Out    int
+	Output TxOutput
+}
+
+// blockWork : the number of hashes that are needed on average to mine a block with the difficulty
+func blockWork(difficulty int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(difficulty))
+}
+
+// storeUndo : store the outputs spent by the connected block with the hash
+func storeUndo(txn Txn, blockHash []byte, spent []spentOutput) error {
+	return txn.Set(append(append([]byte{}, undoPrefix...), blockHash...), encodeUndo(spent))
+}
+
+// undoData : the outputs spent by the connected block, blocks connected before the undo data was
+// stored get them from the transactions of their branch
+func (chain *BlockChain) undoData(txn Txn, block *Block) ([]spentOutput, error) {
+	var spent []spentOutput
+
+	v, err := txn.Get(append(append([]byte{}, undoPrefix...), block.Hash...))
+	if err == nil {
+		return decodeUndo(v)
+	}
+	if err != ErrKeyNotFound {
+		return nil, err
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+		prevTXs, err := chain.prevTransactions(tx, block.PrevHash)
+		if err != nil {
+			return nil, err
+		}
+		for _, in := range tx.Inputs {
+			prevTX := prevTXs[hex.EncodeToString(in.ID)]
+			if in.Out < 0 || in.Out >= len(prevTX.Outputs) {
+				return nil, fmt.Errorf("%w: %x spends the unknown output %d of %x", ErrInvalidTx, tx.ID, in.Out, in.ID)
+			}
+			spent = append(spent, spentOutput{in.ID, in.Out, prevTX.Outputs[in.Out]})
+		}
+	}
+
+	return spent, nil
+}
+
+// ChainWork : the work accumulated by the branch that ends in the block with the hash
+func (chain *BlockChain) ChainWork(blockHash []byte) (*big.Int, error) {
+	work := new(big.Int)
+	var pending []*Block // blocks stored before the work was tracked, from the newest to the oldest
+
+	hash := blockHash
+	for {
+		var found bool
+		err := chain.store.View(func(txn Txn) error {
+			v, err := txn.Get(append(append([]byte{}, workPrefix...), hash...))
+			if err == ErrKeyNotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			work.SetBytes(v)
+			found = true
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			break
+		}
+
+		block, err := chain.GetBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, &block)
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+		hash = block.PrevHash
+	}
+
+	if len(pending) == 0 {
+		return work, nil
+	}
+
+	err := chain.store.Update(func(txn Txn) error {
+		for i := len(pending) - 1; i >= 0; i-- { // the work is added from the oldest block up
+			work.Add(work, blockWork(pending[i].Difficulty))
+			if err := txn.Set(append(append([]byte{}, workPrefix...), pending[i].Hash...), work.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return work, nil
+}
+
+// AcceptBlock : validate a block mined by anyone and store it, the main chain moves to the branch with the most work.
+// The change is nil when the main chain didn't move, an orphan block returns ErrOrphanBlock and is held until its previous block arrives
+func (chain *BlockChain) AcceptBlock(block *Block) (*TipChange, error) {
+	found, err := chain.HasBlock(block.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, nil
+	}
+
+	if len(block.PrevHash) == 0 { // our genesis block is already stored
+		return nil, fmt.Errorf("%w: genesis block %x of another chain", ErrInvalidBlock, block.Hash)
+	}
+
+	parentFound, err := chain.HasBlock(block.PrevHash)
+	if err != nil {
+		return nil, err
+	}
+	if !parentFound {
+		chain.addOrphan(block)
+		return nil, fmt.Errorf("%w: %x", ErrOrphanBlock, block.Hash)
+	}
+
+	change := &TipChange{}
+	blocks := []*Block{block}
+
+	for len(blocks) > 0 {
+		next := blocks[0]
+		blocks = blocks[1:]
+
+		blockChange, err := chain.acceptBlock(next)
+		if err != nil {
+			if next == block {
+				return nil, err
+			}
+			continue // an orphan that breaks the rules is dropped
+		}
+		change.add(blockChange)
+
+		blocks = append(blocks, chain.takeOrphans(next.Hash)...) // the orphans waiting for this block can be accepted now
+	}
+
+	if len(change.Connected) == 0 && len(change.Disconnected) == 0 {
+		return nil, nil
+	}
+
+	return change, nil
+}
+
+// acceptBlock : validate a block whose previous block is stored, it is connected if its branch has more work than the main chain
+func (chain *BlockChain) acceptBlock(block *Block) (*TipChange, error) {
+	if err := chain.ValidateHeader(block); err != nil {
+		return nil, err
+	}
+
+	work, err := chain.ChainWork(block.PrevHash)
+	if err != nil {
+		return nil, err
+	}
+	work.Add(work, blockWork(block.Difficulty))
+
+	tipWork, err := chain.ChainWork(chain.LastHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if work.Cmp(tipWork) <= 0 { // on a tie the block we saw first wins
+		err := chain.store.Update(func(txn Txn) error {
+			return txn.StoreBlock(block, work)
+		})
+		return nil, err
+	}
+
+	return chain.reorganize(block, work)
+}
+
+// reorganize : make the block with the work the tip of the main chain, disconnecting the blocks
+// of the old branch and connecting the blocks of the new one
+func (chain *BlockChain) reorganize(newTip *Block, work *big.Int) (*TipChange, error) {
+	change := &TipChange{}
+
+	parent := func(block *Block) (*Block, error) {
+		prev, err := chain.GetBlock(block.PrevHash)
+		return &prev, err
+	}
+
+	newBranch, err := parent(newTip)
+	if err != nil {
+		return nil, err
+	}
+	oldTip, err := chain.GetBlock(chain.LastHash)
+	if err != nil {
+		return nil, err
+	}
+	oldBranch := &oldTip
+
+	connect := []*Block{newTip} // from the new tip down to the fork point
+	for newBranch.Height > oldBranch.Height {
+		connect = append(connect, newBranch)
+		if newBranch, err = parent(newBranch); err != nil {
+			return nil, err
+		}
+	}
+	for oldBranch.Height > newBranch.Height {
+		change.Disconnected = append(change.Disconnected, oldBranch)
+		if oldBranch, err = parent(oldBranch); err != nil {
+			return nil, err
+		}
+	}
+	for !bytes.Equal(newBranch.Hash, oldBranch.Hash) {
+		connect = append(connect, newBranch)
+		change.Disconnected = append(change.Disconnected, oldBranch)
+		if newBranch, err = parent(newBranch); err != nil {
+			return nil, err
+		}
+		if oldBranch, err = parent(oldBranch); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := len(connect) - 1; i >= 0; i-- {
+		change.Connected = append(change.Connected, connect[i])
+	}
+
+	for _, block := range change.Connected { // the transactions of a side branch are only checked when it becomes the main chain
+		if err := chain.validateTransactions(block); err != nil {
+			if block != newTip { // the stored block breaks the rules, it can't be part of the main chain again
+				if delErr := chain.deleteBlock(block.Hash); delErr != nil {
+					return nil, delErr
+				}
+			}
+			return nil, err
+		}
+	}
+
+	err = chain.store.Update(func(txn Txn) error {
+		if err := txn.StoreBlock(newTip, work); err != nil {
+			return err
+		}
+
+		UTXOSet := UTXOSet{Blockchain: chain}
+		for _, block := range change.Disconnected {
+			spent, err := chain.undoData(txn, block)
+			if err != nil {
+				return err
+			}
+			if err := UTXOSet.disconnect(txn, block, spent); err != nil {
+				return err
+			}
+			if err := chain.disconnectIndex(txn, block); err != nil {
+				return err
+			}
+			if err := txn.Delete(append(append([]byte{}, undoPrefix...), block.Hash...)); err != nil {
+				return err
+			}
+		}
+
+		for _, block := range change.Connected {
+			spent, err := UTXOSet.update(txn, block) // fails if the block spends an output that is not unspent in the new branch
+			if err != nil {
+				return err
+			}
+			if err := storeUndo(txn, block.Hash, spent); err != nil {
+				return err
+			}
+			if err := chain.connectIndex(txn, block, spent); err != nil {
+				return err
+			}
+		}
+
+		return txn.StoreTip(newTip.Hash)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chain.LastHash = newTip.Hash
+	chain.Events.publishChange(change)
+
+	return change, nil
+}
+
+// deleteBlock : delete a block that is not part of the main chain
+func (chain *BlockChain) deleteBlock(blockHash []byte) error {
+	return chain.store.Update(func(txn Txn) error {
+		return txn.DeleteBlock(blockHash)
+	})
+}
+
+// add : append the moves of the next change, a block connected and then disconnected is removed from both lists
+func (change *TipChange) add(next *TipChange) {
+	if next == nil {
+		return
+	}
+
+	for _, block := range next.Disconnected {
+		if n := len(change.Connected); n > 0 && bytes.Equal(change.Connected[n-1].Hash, block.Hash) {
+			change.Connected = change.Connected[:n-1]
+			continue
+		}
+		change.Disconnected = append(change.Disconnected, block)
+	}
+	change.Connected = append(change.Connected, next.Connected...)
+}
+
+// addOrphan : hold the block until its previous block arrives, when the pool is full an arbitrary group of orphans is dropped
+func (chain *BlockChain) addOrphan(block *Block) {
+	if chain.orphans == nil {
+		chain.orphans = make(map[string][]*Block)
+	}
+
+	prevHash := hex.EncodeToString(block.PrevHash)
+	for _, orphan := range chain.orphans[prevHash] {
+		if bytes.Equal(orphan.Hash, block.Hash) {
+			return
+		}
+	}
+
+	for prev, orphans := range chain.orphans {
+		if chain.orphanCount < maxOrphans {
+			break
+		}
+		chain.orphanCount -= len(orphans)
+		delete(chain.orphans, prev)
+	}
+
+	chain.orphans[prevHash] = append(chain.orphans[prevHash], block)
+	chain.orphanCount++
+}
+
+// takeOrphans : remove and return the orphans whose previous block has the hash
+func (chain *BlockChain) takeOrphans(prevHash []byte) []*Block {
+	key := hex.EncodeToString(prevHash)
+	orphans := chain.orphans[key]
+
+	delete(chain.orphans, key)
+	chain.orphanCount -= len(orphans)
+
+	return orphans
+}
+
+// OrphanCount : number of orphan blocks waiting for their previous block
+func (chain *BlockChain) OrphanCount() int {
+	return chain.orphanCount
+}
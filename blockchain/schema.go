@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+	Every key of the database starts with the namespace of what it holds, so the kinds of records
+	never collide and each kind can be read or deleted by its prefix. The version of the layout is
+	stored in meta/schema, a database written by an older version must be upgraded with the migrate
+	command before it can be opened, and a database written by a newer version is refused instead
+	of being read with the wrong layout. The keys of schema 1 are:
+
+		block/<hash>                      block encoded with Block.Serialize
+		work/<hash>                       work of the branch that ends in the block
+		undo/<hash>                       outputs spent by the block while it is in the main chain
+		utxo/<txid>                       unspent outputs of the transaction
+		mempool/<txid>                    transaction waiting to be mined
+		index/height/<height>             hash of the block of the main chain at the height
+		index/tx/<txid>                   hash of the block of the transaction and its position
+		index/addr/<pkh><height><pos>     value received and sent by the key in the transaction
+		meta/tip                          hash of the last block of the main chain
+		meta/schema                       version of the layout
+		meta/txindex, meta/addrindex      set once the optional indexes are complete
+
+	Schema 0 is the layout used before the version was stored: the blocks were stored by their
+	bare hash, the tip was "lh" and the other prefixes ended with a dash, like "utxo-", and the
+	records could still be encoded with gob.
+
+	Esp:
+
+	Cada llave de la base de datos empieza con el espacio de nombres de lo que guarda, asi los tipos
+	de registros nunca chocan y cada tipo se puede leer o borrar por su prefijo. La versión del
+	formato se guarda en meta/schema, una base de datos escrita por una versión anterior se debe
+	actualizar con el comando migrate antes de poder abrirla, y una base de datos escrita por una
+	versión mas nueva se rechaza en vez de leerla con el formato equivocado. Las llaves del esquema
+	1 son las de la tabla de arriba.
+
+	El esquema 0 es el formato que se usaba antes de guardar la versión: los bloques se guardaban
+	solo por su hash, la punta era "lh" y los otros prefijos terminaban con un guion, como "utxo-",
+	y los registros podían estar codificados con gob todavía.
+*/
+
+// SchemaVersion : version of the layout of the keys written by this program
+const SchemaVersion = 1
+
+var (
+	blockPrefix   = []byte("block/")
+	workPrefix    = []byte("work/")
+	undoPrefix    = []byte("undo/")
+	utxoPrefix    = []byte("utxo/")
+	mempoolPrefix = []byte("mempool/")
+	heightPrefix  = []byte("index/height/")
+	txPrefix      = []byte("index/tx/")
+	addrPrefix    = []byte("index/addr/")
+	tipKey        = []byte("meta/tip")
+	schemaKey     = []byte("meta/schema")
+	txIndexKey    = []byte("meta/txindex")   // set once every transaction of the main chain is in the tx index
+	addrIndexKey  = []byte("meta/addrindex") // set once every transaction of the main chain is in the address index
+)
+
+// blockKey : key of the block with the hash
+func blockKey(hash []byte) []byte {
+	return append(append([]byte{}, blockPrefix...), hash...)
+}
+
+// setSchema : store the version of the layout written by this program
+func setSchema(txn Txn) error {
+	version := make([]byte, 4)
+	binary.BigEndian.PutUint32(version, SchemaVersion)
+
+	return txn.Set(schemaKey, version)
+}
+
+// readSchema : version of the layout of the database, 0 when it was stored before the version existed.
+// An empty database has nothing to migrate, it has the current version
+func readSchema(txn Txn) (int, error) {
+	v, err := txn.Get(schemaKey)
+	if err == ErrKeyNotFound {
+		it := txn.NewIterator(nil, true)
+		defer it.Close()
+
+		if it.Rewind(); !it.Valid() {
+			return SchemaVersion, nil
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 4 {
+		return 0, fmt.Errorf("%w: the schema version has %d bytes", ErrInvalidEncoding, len(v))
+	}
+
+	return int(binary.BigEndian.Uint32(v)), nil
+}
+
+// checkSchema : the database can be opened by this program without migrating it
+func checkSchema(txn Txn) error {
+	version, err := readSchema(txn)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version > SchemaVersion:
+		return schemaTooNew(version)
+	case version < SchemaVersion:
+		return fmt.Errorf("%w: the database has schema %d, run migrate to upgrade it to schema %d", ErrSchemaOutdated, version, SchemaVersion)
+	}
+
+	return nil
+}
+
+// schemaTooNew : error of a database with a schema newer than SchemaVersion
+func schemaTooNew(version int) error {
+	return fmt.Errorf("%w: the database has schema %d and this program understands up to schema %d", ErrSchemaTooNew, version, SchemaVersion)
+}
@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+/*
+	The chain doesn't talk to a database directly, everything it keeps goes through a Store, a
+	sorted key value store with transactions. A transaction made with Update is atomic, all of its
+	writes are stored or none of them, and it sees its own writes, a transaction made with View
+	only reads. Two transactions made with Update at the same time can't both change what the
+	other one read: the one that commits last fails with ErrConflict and can be run again.
+
+	The keys are described in schema.go. The blocks and the tip have their own methods in the
+	transaction, so a store can keep them apart from the other keys, BadgerStore and MemoryStore
+	keep them in the keys of schema.go with keyBlocks. BadgerStore keeps the chain in a badger
+	database on disk and MemoryStore keeps it in memory, for tests and simulations that don't need
+	to touch the disk.
+
+	Esp:
+
+	La cadena no habla directamente con una base de datos, todo lo que guarda pasa por un Store, un
+	almacén de llaves y valores ordenado con transacciones. Una transacción hecha con Update es
+	atómica, todas sus escrituras se guardan o ninguna, y ve sus propias escrituras, una transacción
+	hecha con View solo lee. Dos transacciones hechas con Update al mismo tiempo no pueden cambiar
+	ambas lo que la otra leyó: la que confirma al último falla con ErrConflict y se puede correr de
+	nuevo.
+
+	Las llaves se describen en schema.go. Los bloques y la punta tienen sus propios métodos en la
+	transacción, asi un store los puede guardar aparte de las otras llaves, BadgerStore y
+	MemoryStore los guardan en las llaves de schema.go con keyBlocks. BadgerStore guarda la cadena
+	en una base de datos de badger en el disco y MemoryStore la guarda en memoria, para pruebas y
+	simulaciones que no necesitan tocar el disco.
+*/
+
+var (
+	// ErrKeyNotFound : the key is not in the store
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrConflict : a transaction committed while the transaction was running changed a key that it read
+	ErrConflict = errors.New("transaction conflict, run it again")
+)
+
+// Store : storage of the chain
+type Store interface {
+	View(fn func(txn Txn) error) error   // run fn in a read only transaction
+	Update(fn func(txn Txn) error) error // run fn in a transaction that is stored only if fn returns nil
+	Close() error
+}
+
+// Txn : a transaction of a Store, it can't be used after the function that received it returns
+type Txn interface {
+	KeyValueTxn
+
+	ReadBlock(hash []byte) (*Block, error)        // ErrBlockNotFound when the block is not stored
+	StoreBlock(block *Block, work *big.Int) error // the block and the work of the branch that ends in it
+	DeleteBlock(hash []byte) error                // the block and its work
+	ReadTip() ([]byte, error)                     // hash of the last block of the main chain, ErrKeyNotFound without a chain
+	StoreTip(hash []byte) error                   // make the block with the hash the last block of the main chain
+}
+
+// KeyValueTxn : the keys and values of a transaction
+type KeyValueTxn interface {
+	Get(key []byte) ([]byte, error) // a copy of the value of the key, ErrKeyNotFound when it's not stored
+	Set(key, value []byte) error    // the key and the value can't be changed until the transaction ends
+	Delete(key []byte) error
+	NewIterator(prefix []byte, keysOnly bool) Iterator // walks the keys that start with the prefix, keysOnly when the values won't be read
+}
+
+// Iterator : walks the keys of a prefix in ascending order, a transaction can have one open iterator
+type Iterator interface {
+	Rewind()                // move to the first key of the prefix
+	Seek(key []byte)        // move to the first key of the prefix that is equal or greater than the key
+	Valid() bool            // the iterator is on a key of the prefix
+	Next()                  // move to the next key
+	Key() []byte            // a copy of the current key
+	Value() ([]byte, error) // a copy of the value of the current key
+	Close()
+}
+
+// keyBlocks : the block and tip methods of a Txn that keeps them in the keys of schema.go
+type keyBlocks struct {
+	kv KeyValueTxn
+}
+
+// ReadBlock : the block with the hash
+func (k keyBlocks) ReadBlock(hash []byte) (*Block, error) {
+	encoded, err := k.kv.Get(blockKey(hash))
+	if err == ErrKeyNotFound {
+		return nil, fmt.Errorf("%w: %x", ErrBlockNotFound, hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return Deserialize(encoded)
+}
+
+// StoreBlock : store the block and the work of the branch that ends in it
+func (k keyBlocks) StoreBlock(block *Block, work *big.Int) error {
+	encoded, err := block.Serialize()
+	if err != nil {
+		return err
+	}
+
+	if err := k.kv.Set(blockKey(block.Hash), encoded); err != nil {
+		return err
+	}
+
+	return k.kv.Set(append(append([]byte{}, workPrefix...), block.Hash...), work.Bytes())
+}
+
+// DeleteBlock : delete the block and its work
+func (k keyBlocks) DeleteBlock(hash []byte) error {
+	if err := k.kv.Delete(blockKey(hash)); err != nil {
+		return err
+	}
+
+	return k.kv.Delete(append(append([]byte{}, workPrefix...), hash...))
+}
+
+// ReadTip : hash of the last block of the main chain
+func (k keyBlocks) ReadTip() ([]byte, error) {
+	return k.kv.Get(tipKey)
+}
+
+// StoreTip : make the block with the hash the last block of the main chain
+func (k keyBlocks) StoreTip(hash []byte) error {
+	return k.kv.Set(tipKey, hash)
+}
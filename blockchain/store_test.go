@@ -0,0 +1,174 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+// testStores : the options of an empty chain of the test network in each kind of store
+func testStores(t *testing.T) map[string]func() Options {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	memory := NewMemoryStore()
+
+	return map[string]func() Options{
+		"memory": func() Options { return Options{DataDir: dir, Network: "test", Store: memory} },
+		"badger": func() Options { return Options{DataDir: dir, Network: "test"} }, // opened again by each chain
+	}
+}
+
+func TestStoreConflicts(t *testing.T) {
+	for name, opts := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store, err := opts().openStore()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			set := func(key, value string) error {
+				return store.Update(func(txn Txn) error { return txn.Set([]byte(key), []byte(value)) })
+			}
+			get := func(key string) string {
+				var value []byte
+				store.View(func(txn Txn) error {
+					value, _ = txn.Get([]byte(key))
+					return nil
+				})
+				return string(value)
+			}
+			if err := set("a/1", "1"); err != nil {
+				t.Fatal(err)
+			}
+
+			err = store.Update(func(txn Txn) error {
+				if _, err := txn.Get([]byte("a/1")); err != nil {
+					return err
+				}
+				if err := set("a/1", "2"); err != nil { // committed while the first one runs
+					return err
+				}
+				return txn.Set([]byte("a/1"), []byte("3"))
+			})
+			if !errors.Is(err, ErrConflict) {
+				t.Errorf("a write based on a value changed by another transaction: %v", err)
+			}
+			if value := get("a/1"); value != "2" {
+				t.Errorf("a/1 is %q, the transaction that conflicted overwrote it", value)
+			}
+
+			err = store.Update(func(txn Txn) error {
+				it := txn.NewIterator([]byte("a/"), true)
+				for it.Rewind(); it.Valid(); it.Next() {
+					it.Key()
+				}
+				it.Close()
+				if err := set("a/1", "4"); err != nil {
+					return err
+				}
+				return txn.Set([]byte("b"), []byte("count"))
+			})
+			if !errors.Is(err, ErrConflict) {
+				t.Errorf("a write based on the keys of an iterator changed by another transaction: %v", err)
+			}
+
+			err = store.Update(func(txn Txn) error {
+				if err := set("a/1", "5"); err != nil {
+					return err
+				}
+				return txn.Set([]byte("c"), []byte("blind")) // doesn't depend on what the other one changed
+			})
+			if err != nil {
+				t.Errorf("two transactions that change different keys without reading them: %v", err)
+			}
+			if get("a/1") != "5" || get("c") != "blind" {
+				t.Errorf("the changes of both transactions were not kept: %q %q", get("a/1"), get("c"))
+			}
+		})
+	}
+}
+
+func TestChainOnStores(t *testing.T) {
+	for name, opts := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			from, err := wallet.MakeWallet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			to, err := wallet.MakeWallet()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			chain, err := InitBlockChain(string(from.Address()), opts())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				cbTx, err := chain.NewCoinbase(string(from.Address()), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := chain.AddBlock([]*Transaction{cbTx}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tx, err := NewTransaction(from, string(to.Address()), 30, 2, &UTXOSet{Blockchain: chain})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cbTx, err := chain.NewCoinbase(string(to.Address()), []*Transaction{tx})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := chain.AddBlock([]*Transaction{cbTx, tx}); err != nil {
+				t.Fatal(err)
+			}
+			tip := chain.LastHash
+			if err := chain.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			chain, err = ContinueBlockChain(opts())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer chain.Close()
+
+			if !bytes.Equal(chain.LastHash, tip) {
+				t.Errorf("the tip is %x after opening the chain again, expected %x", chain.LastHash, tip)
+			}
+			if height, err := chain.GetBestHeight(); err != nil || height != 3 {
+				t.Errorf("height %d, %v, expected 3", height, err)
+			}
+			if _, err := chain.Verify(0); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+
+			UTXOSet := UTXOSet{Blockchain: chain}
+			outs, err := UTXOSet.FindUTXO(wallet.PublicKeyHash(to.PublicKey))
+			if err != nil {
+				t.Fatal(err)
+			}
+			balance := 0
+			for _, out := range outs {
+				balance += out.Value
+			}
+			if expected := 30 + chain.Params.Subsidy(3) + 2; balance != expected {
+				t.Errorf("balance %d, expected %d", balance, expected)
+			}
+		})
+	}
+}
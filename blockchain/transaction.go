@@ -2,55 +2,35 @@ package blockchain
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
-	"encoding/gob"
 	"encoding/hex"
 	"fmt"
-	"log"
+	"math/big"
+	"strings"
+
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	sigLength = 32                 // bytes of each half of a P-256 signature or public key
+	maxValue  = int(^uint(0) >> 1) // largest sum of coins, the largest int
 )
 
+// TxVersion : version of the transactions created by this node, the version 2 hashes the binary format
+const TxVersion = 2
+
+// Transaction : moves tokens from the outputs referenced by the inputs to new outputs
 type Transaction struct {
+	Version int    //how the ID and the signatures are hashed
 	ID      []byte //hash
 	Inputs  []TxInput
 	Outputs []TxOutput
 }
 
-type TxInput struct {
-	ID  []byte //references the transaction that the output is inside of
-	Out int    //index of the output
-	Sig string // is a script witch provides the data witch is used in the outputs Pubkey
-	// but in this impl the Sig is going to be the users account
-	//inputs are just references to previous outputs
-}
-
-type TxOutput struct {
-	Value  int    //value in tokens
-	Pubkey string //needed to unlock tokens inside value field
-
-	//Outputs are indivisible you can't reference a part of an output
-	/*
-		An analogy to this is :
-		if you walk into a store and you buy 5 dolars then pay with 10 dollars to the cashier
-		the cashier cant just rip the 10 dollar bill on half and hand you the other half
-		you back, instead he have to give you back a 5 dollar bill.
-
-		So there is 10 tokens inside of our output we need to create new outputs one with
-		5 tokens inside of it and another one with 5 tokens inside of it.
-
-		Esp:
-
-		- Los Outputs son indivisibles, no puedes hacer referencia a solo una parte de este.
-
-		Una analogia :
-		Si entras a una tienda y compras 5 dolares en productos luego le pagas al cajero con un
-		billete de 10 dolares el cajero no puede solo partir el billete por la mitad y luego darte
-		la otra mitad, en vez de eso tiene que darte de vuelta otro billete de 5 dolares
-
-		Asique si hay 10 tokens dentro de nuestro output necesitariamos crear nuevos outputs, uno con 5
-		tokens dentro y otro con 5 tokens dentro también.
-	*/
-}
-
 /*
 	In our blockchain we have our genesis block in that block we
 	also have our first transaction this is wath's called a coinbase transaction,
@@ -74,65 +54,262 @@ type TxOutput struct {
 	para hacer la cosas mas simples por ahora.
 */
 
-//SetID : Creates a hash based on bytes that represents the transaction
-func (tx *Transaction) SetID() {
-	var encoded bytes.Buffer
-	var hash [32]byte
+// Serialize : binary encoding of the transaction, described in encoding.go
+func (tx Transaction) Serialize() ([]byte, error) {
+	e := newEncoder()
+	e.writeTx(&tx)
+
+	return e.buffer.Bytes(), nil
+}
+
+// DeserializeTransaction : decode a transaction encoded with Serialize or with gob by older versions
+func DeserializeTransaction(data []byte) (Transaction, error) {
+	var transaction Transaction
+
+	if isLegacy(data) {
+		err := decodeLegacy(data, &transaction)
+		transaction.Version = 1 // gob transactions were created before the versions
+		return transaction, err
+	}
+
+	d := newDecoder(data)
+	tx := d.readTx()
+	if err := d.finish(); err != nil {
+		return transaction, err
+	}
+
+	return *tx, nil
+}
+
+/*
+	The ID and the signatures are computed from the bytes of hashData and not from the gob encoding,
+	gob numbers the types in the order that each process uses them for the first time, so two nodes
+	could get different hashes for the same transaction. A transaction of version 2 is hashed as
+	its body in the binary format of encoding.go with an empty ID, a transaction of version 1 as
+	every field in a fixed order, numbers with 8 bytes and byte slices preceded by their length.
+
+	Esp:
+
+	El ID y las firmas se calculan con los bytes de hashData y no con la codificación gob, gob numera
+	los tipos en el orden en que cada proceso los usa por primera vez, asi dos nodos podrían obtener
+	hashes distintos para la misma transacción. Una transacción de versión 2 se hashea como su cuerpo
+	en el formato binario de encoding.go con un ID vacío, una transacción de versión 1 como cada
+	campo en un orden fijo, los números con 8 bytes y los slices de bytes precedidos por su largo.
+*/
+
+// hashData : bytes of the transaction without its ID that are hashed
+func (tx *Transaction) hashData() []byte {
+	if tx.Version >= 2 {
+		unsigned := *tx
+		unsigned.ID = nil
+		var e encoder // without the version byte of the records
+		e.writeTx(&unsigned)
+		return e.buffer.Bytes()
+	}
+
+	var data bytes.Buffer
+
+	writeBytes := func(b []byte) {
+		data.Write(ToHex(int64(len(b))))
+		data.Write(b)
+	}
+
+	data.Write(ToHex(int64(len(tx.Inputs))))
+	for _, in := range tx.Inputs {
+		writeBytes(in.ID)
+		data.Write(ToHex(int64(in.Out)))
+		writeBytes(in.Signature)
+		writeBytes(in.PubKey)
+	}
+
+	data.Write(ToHex(int64(len(tx.Outputs))))
+	for _, out := range tx.Outputs {
+		data.Write(ToHex(int64(out.Value)))
+		writeBytes(out.PubKeyHash)
+	}
+
+	return data.Bytes()
+}
+
+// Hash : hash of the transaction without its ID
+func (tx *Transaction) Hash() ([]byte, error) {
+	hash := sha256.Sum256(tx.hashData())
+
+	return hash[:], nil
+}
+
+// checkID : check that the ID is the hash of the transaction as it was before the inputs were signed
+func (tx *Transaction) checkID() error {
+	if tx.Version < 1 || tx.Version > TxVersion {
+		return fmt.Errorf("%w: %x has an unknown version %d", ErrInvalidTx, tx.ID, tx.Version)
+	}
+
+	unsigned := *tx
+	unsigned.Inputs = make([]TxInput, len(tx.Inputs))
+	for i, in := range tx.Inputs {
+		unsigned.Inputs[i] = TxInput{in.ID, in.Out, nil, in.PubKey}
+	}
 
-	encode := gob.NewEncoder(&encoded)
-	err := encode.Encode(tx) // encode the transaction
-	Handle(err)
+	hash, err := unsigned.Hash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, tx.ID) {
+		return fmt.Errorf("%w: %x: ID does not match its content", ErrInvalidTx, tx.ID)
+	}
+
+	return nil
+}
+
+//SetID : Creates a hash based on bytes that represents the transaction
+func (tx *Transaction) SetID() error {
+	hash, err := tx.Hash()
+	if err != nil {
+		return err
+	}
+	tx.ID = hash
 
-	hash = sha256.Sum256(encoded.Bytes()) //hash the bytes portion of our encoded bytes
-	tx.ID = hash[:]                       // set that hash into the transaction id
+	return nil
 }
 
-// CoinbaseTx :
-func CoinbaseTx(to, data string) *Transaction {
-	if data == "" { //empty
-		data = fmt.Sprint("Coins to %s", to)
+// CoinbaseTx : transaction that creates value coins for the miner of a block
+func CoinbaseTx(to, data string, value int) (*Transaction, error) {
+	if data == "" { //empty, random data so two coinbases to the same address never get the same ID
+		randData := make([]byte, 24)
+		if _, err := rand.Read(randData); err != nil {
+			return nil, err
+		}
+		data = fmt.Sprintf("%x", randData)
+	}
+	txin := TxInput{[]byte{}, -1, nil, []byte(data)} //empty slice of bytes for id, outIndex = -1, no signature, arbitrary data instead of a public key
+	var outputs []TxOutput
+	if value > 0 { // outputs of 0 coins are invalid, a coinbase that creates nothing has no outputs
+		txout, err := NewTXOutput(value, to) //reward, locked to the "to" address
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, *txout)
 	}
-	txin := TxInput{[]byte{}, -1, data} //empty slice of bytes for id, outIndex = -1, signature
-	txout := TxOutput{100, to}          //reward, pubkey string for this output as a reference to the "to" address
 
 	//Instance of the transaction struct
-	tx := Transaction{nil, []TxInput{txin}, []TxOutput{txout}} //nil for id, inp, out
-	tx.SetID()                                                 //create hash id for this transaction
+	tx := Transaction{TxVersion, nil, []TxInput{txin}, outputs} //nil for id, inp, out
+	err := tx.SetID()                                           //create hash id for this transaction
 
-	return &tx //return a reference for this transaction
+	return &tx, err //return a reference for this transaction
 }
 
-// NewTransaction : creates a new transaction
-func NewTransaction(from, to string, amount int, chain *BlockChain) *Transaction {
+// NewTransaction : creates a new transaction signed by the wallet, the fee is the difference between the inputs and the outputs
+func NewTransaction(w *wallet.Wallet, to string, amount, fee int, UTXO *UTXOSet) (*Transaction, error) {
 	var inputs []TxInput
 	var outputs []TxOutput
 
-	acc, validOutputs := chain.FindSpendableOutputs(from, amount)
+	from := string(w.Address())
+	pubKeyHash := wallet.PublicKeyHash(w.PublicKey)
+
+	if fee < 0 {
+		return nil, fmt.Errorf("%w: negative fee %d", ErrInvalidTx, fee)
+	}
+
+	acc, validOutputs, err := UTXO.FindSpendableOutputs(pubKeyHash, amount+fee)
+	if err != nil {
+		return nil, err
+	}
 
-	if acc < amount { // check if the amount is greater than the accumulator
-		log.Panic("Error: not enough funds")
+	if acc < amount+fee { // check if the amount is greater than the accumulator
+		return nil, fmt.Errorf("%w: %s has %d, needs %d", ErrInsufficientFunds, from, acc, amount+fee)
 	}
 
 	for txid, outs := range validOutputs { // iterate through  valid outputs
 		txID, err := hex.DecodeString(txid) // take each and decode the string txid into bytes
-		Handle(err)
+		if err != nil {
+			return nil, err
+		}
 
 		for _, out := range outs { // iterate through the outs
-			input := TxInput{txID, out, from} // creat a new input for each of the unspent outputs
+			input := TxInput{txID, out, nil, w.PublicKey} // creat a new input for each of the unspent outputs, the signature is added later
 			inputs = append(inputs, input)
 		}
 	}
 
-	outputs = append(outputs, TxOutput{amount, to}) // create an output with the amount that we are going to send and then the "to" address which is the person that we are sending to
+	output, err := NewTXOutput(amount, to) // create an output with the amount that we are going to send and then the "to" address which is the person that we are sending to
+	if err != nil {
+		return nil, err
+	}
+	outputs = append(outputs, *output)
 
-	if acc > amount { // check if the amount is less than the accumulated which means that the amount that the from user has is greater than the amount that he's trying to send
-		outputs = append(outputs, TxOutput{acc - amount, from}) // create a second output. Is created if there is any left over tokens in the original sender account
+	if acc > amount+fee { // check if the amount is less than the accumulated which means that the amount that the from user has is greater than the amount that he's trying to send
+		change, err := NewTXOutput(acc-amount-fee, from) // create a second output. Is created if there is any left over tokens in the original sender account
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, *change)
 	}
 
-	tx := Transaction{nil, inputs, outputs} // instancies a transaction and passed an inputs and an outputs
-	tx.SetID()                              //set the id of the transaction
+	tx := Transaction{TxVersion, nil, inputs, outputs} // instancies a transaction and passed an inputs and an outputs
+	if err := tx.SetID(); err != nil {                 //set the id of the transaction
+		return nil, err
+	}
+	if err := UTXO.Blockchain.SignTransaction(&tx, w.PrivateKey); err != nil {
+		return nil, err
+	}
 
-	return &tx // return the reference to the transaction
+	return &tx, nil // return the reference to the transaction
+}
+
+// Fee : inputs minus outputs of the transaction, prevTXs has the transactions referenced by the inputs
+func (tx *Transaction) Fee(prevTXs map[string]Transaction) (int, error) {
+	inputs := 0
+	for _, in := range tx.Inputs {
+		prevTX, ok := prevTXs[hex.EncodeToString(in.ID)]
+		if !ok {
+			return 0, fmt.Errorf("%w: previous transaction %x", ErrTxNotFound, in.ID)
+		}
+		if in.Out < 0 || in.Out >= len(prevTX.Outputs) {
+			return 0, fmt.Errorf("%w: output %d of %x does not exist", ErrInvalidTx, in.Out, in.ID)
+		}
+		if inputs, ok = addValue(inputs, prevTX.Outputs[in.Out].Value); !ok {
+			return 0, fmt.Errorf("%w: the inputs of %x overflow", ErrInvalidTx, tx.ID)
+		}
+	}
+
+	outputs, err := tx.outputsValue()
+	if err != nil {
+		return 0, err
+	}
+	if outputs > inputs { // a transaction can't create coins
+		return 0, fmt.Errorf("%w: %x spends %d but its outputs add up to %d", ErrInvalidTx, tx.ID, inputs, outputs)
+	}
+
+	return inputs - outputs, nil
+}
+
+// outputsValue : coins of the outputs of the transaction, every output must have a positive value and
+// be locked to a public key hash
+func (tx *Transaction) outputsValue() (int, error) {
+	total := 0
+	for _, out := range tx.Outputs {
+		if out.Value <= 0 {
+			return 0, fmt.Errorf("%w: %x has an output with value %d", ErrInvalidTx, tx.ID, out.Value)
+		}
+		if len(out.PubKeyHash) != ripemd160.Size { // the keys of the address index need hashes of the same length
+			return 0, fmt.Errorf("%w: %x has an output locked to %d bytes instead of a public key hash", ErrInvalidTx, tx.ID, len(out.PubKeyHash))
+		}
+		var ok bool
+		if total, ok = addValue(total, out.Value); !ok {
+			return 0, fmt.Errorf("%w: the outputs of %x overflow", ErrInvalidTx, tx.ID)
+		}
+	}
+
+	return total, nil
+}
+
+// addValue : sum plus value, false when value is negative or the sum doesn't fit in an int
+func addValue(sum, value int) (int, bool) {
+	if value < 0 || sum > maxValue-value {
+		return 0, false
+	}
+
+	return sum + value, true
 }
 
 //IsCoinbase : Allow us tho determine if a transaction is a coninbase transaction or not
@@ -140,10 +317,147 @@ func (tx *Transaction) IsCoinbase() bool {
 	return len(tx.Inputs) == 1 && len(tx.Inputs[0].ID) == 0 && tx.Inputs[0].Out == -1 // if all is true is a coinbase transaction
 }
 
-func (in *TxInput) CanUnlock(data string) bool {
-	return in.Sig == data // check if is equals to the signature attached to the input struct
+/*
+	Signing:
+
+	The signature can't sign itself, so each input is signed over a trimmed copy of the transaction,
+	a copy without signatures and without public keys. Before hashing the copy, the input that is being
+	signed gets the public key hash of the output that it references, so every signature covers the
+	outputs that are being spent and the new outputs of the transaction.
+
+	Esp:
+
+	La firma no puede firmarse a si misma, asique cada input se firma sobre una copia recortada de la
+	transacción, una copia sin firmas y sin llaves publicas. Antes de hacer el hash de la copia, al input
+	que se esta firmando se le asigna el hash de la llave publica del output al que hace referencia, asi
+	cada firma cubre los outputs que se están gastando y los nuevos outputs de la transacción.
+*/
+
+// Sign : sign each input of the transaction, prevTXs holds the transactions referenced by the inputs
+func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) error {
+	if tx.IsCoinbase() { // coinbase transactions don't reference outputs, there is nothing to sign
+		return nil
+	}
+
+	for _, in := range tx.Inputs {
+		prevTX := prevTXs[hex.EncodeToString(in.ID)]
+		if prevTX.ID == nil {
+			return fmt.Errorf("%w: previous transaction %x", ErrTxNotFound, in.ID)
+		}
+		if in.Out < 0 || in.Out >= len(prevTX.Outputs) {
+			return fmt.Errorf("%w: output %d of %x does not exist", ErrInvalidTx, in.Out, in.ID)
+		}
+	}
+
+	txCopy := tx.TrimmedCopy()
+
+	for inId, in := range txCopy.Inputs {
+		prevTX := prevTXs[hex.EncodeToString(in.ID)]
+		txCopy.Inputs[inId].Signature = nil
+		txCopy.Inputs[inId].PubKey = prevTX.Outputs[in.Out].PubKeyHash
+		if err := txCopy.SetID(); err != nil {
+			return err
+		}
+		txCopy.Inputs[inId].PubKey = nil
+
+		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
+		if err != nil {
+			return err
+		}
+
+		signature := make([]byte, 2*sigLength) // r and s padded to the same length so they can be split in half
+		r.FillBytes(signature[:sigLength])
+		s.FillBytes(signature[sigLength:])
+
+		tx.Inputs[inId].Signature = signature
+	}
+
+	return nil
 }
 
-func (out *TxOutput) CanBeUnlocked(data string) bool {
-	return out.Pubkey == data //check if is equal to the pubkey if is true means that the account(data) owns information inside the output
+// Verify : check the signatures of each input against the outputs that they reference
+func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+	if tx.IsCoinbase() {
+		return true
+	}
+
+	for _, in := range tx.Inputs {
+		prevTX := prevTXs[hex.EncodeToString(in.ID)]
+		if prevTX.ID == nil || in.Out < 0 || in.Out >= len(prevTX.Outputs) {
+			return false
+		}
+		if !in.UsesKey(prevTX.Outputs[in.Out].PubKeyHash) { // the public key must belong to the owner of the output
+			return false
+		}
+	}
+
+	txCopy := tx.TrimmedCopy()
+	curve := elliptic.P256()
+
+	for inId, in := range tx.Inputs {
+		prevTX := prevTXs[hex.EncodeToString(in.ID)]
+		txCopy.Inputs[inId].Signature = nil
+		txCopy.Inputs[inId].PubKey = prevTX.Outputs[in.Out].PubKeyHash
+		if err := txCopy.SetID(); err != nil {
+			return false
+		}
+		txCopy.Inputs[inId].PubKey = nil
+
+		if len(in.Signature) != 2*sigLength || len(in.PubKey) != 2*sigLength {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(in.Signature[:sigLength])
+		s := new(big.Int).SetBytes(in.Signature[sigLength:])
+
+		x := new(big.Int).SetBytes(in.PubKey[:sigLength])
+		y := new(big.Int).SetBytes(in.PubKey[sigLength:])
+
+		rawPubKey := ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if !curve.IsOnCurve(x, y) || !ecdsa.Verify(&rawPubKey, txCopy.ID, r, s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TrimmedCopy : copy of the transaction without signatures and public keys
+func (tx *Transaction) TrimmedCopy() Transaction {
+	var inputs []TxInput
+	var outputs []TxOutput
+
+	for _, in := range tx.Inputs {
+		inputs = append(inputs, TxInput{in.ID, in.Out, nil, nil})
+	}
+
+	for _, out := range tx.Outputs {
+		outputs = append(outputs, TxOutput{out.Value, out.PubKeyHash})
+	}
+
+	txCopy := Transaction{tx.Version, tx.ID, inputs, outputs}
+
+	return txCopy
+}
+
+// String : human readable representation of the transaction
+func (tx Transaction) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Transaction %x:", tx.ID))
+	for i, input := range tx.Inputs {
+		lines = append(lines, fmt.Sprintf("     Input %d:", i))
+		lines = append(lines, fmt.Sprintf("       TXID:     %x", input.ID))
+		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Out))
+		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
+		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+	}
+
+	for i, output := range tx.Outputs {
+		lines = append(lines, fmt.Sprintf("     Output %d:", i))
+		lines = append(lines, fmt.Sprintf("       Value:  %d", output.Value))
+		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
+	}
+
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"bytes"
+
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+// TxInput : reference to an output of a previous transaction
+type TxInput struct {
+	ID        []byte //references the transaction that the output is inside of
+	Out       int    //index of the output
+	Signature []byte //signature of the trimmed copy of the transaction made with the private key of the owner
+	PubKey    []byte //public key of the owner, its hash has to match the PubKeyHash of the referenced output
+	//inputs are just references to previous outputs
+}
+
+// TxOutput : amount of tokens locked to a public key hash
+type TxOutput struct {
+	Value      int    //value in tokens
+	PubKeyHash []byte //needed to unlock tokens inside value field
+
+	//Outputs are indivisible you can't reference a part of an output
+	/*
+		An analogy to this is :
+		if you walk into a store and you buy 5 dolars then pay with 10 dollars to the cashier
+		the cashier cant just rip the 10 dollar bill on half and hand you the other half
+		you back, instead he have to give you back a 5 dollar bill.
+
+		So there is 10 tokens inside of our output we need to create new outputs one with
+		5 tokens inside of it and another one with 5 tokens inside of it.
+
+		Esp:
+
+		- Los Outputs son indivisibles, no puedes hacer referencia a solo una parte de este.
+
+		Una analogia :
+		Si entras a una tienda y compras 5 dolares en productos luego le pagas al cajero con un
+		billete de 10 dolares el cajero no puede solo partir el billete por la mitad y luego darte
+		la otra mitad, en vez de eso tiene que darte de vuelta otro billete de 5 dolares
+
+		Asique si hay 10 tokens dentro de nuestro output necesitariamos crear nuevos outputs, uno con 5
+		tokens dentro y otro con 5 tokens dentro también.
+	*/
+}
+
+// NewTXOutput : creates an output locked to the address
+func NewTXOutput(value int, address string) (*TxOutput, error) {
+	txo := &TxOutput{value, nil}
+	if err := txo.Lock([]byte(address)); err != nil {
+		return nil, err
+	}
+
+	return txo, nil
+}
+
+// UsesKey : check if the input was created by the owner of the public key hash
+func (in *TxInput) UsesKey(pubKeyHash []byte) bool {
+	lockingHash := wallet.PublicKeyHash(in.PubKey)
+
+	return bytes.Equal(lockingHash, pubKeyHash)
+}
+
+// Lock : lock the output to the public key hash inside of the address
+func (out *TxOutput) Lock(address []byte) error {
+	pubKeyHash, err := wallet.PubKeyHashFromAddress(string(address))
+	if err != nil {
+		return err
+	}
+
+	out.PubKeyHash = pubKeyHash
+
+	return nil
+}
+
+// IsLockedWithKey : check if the output can be unlocked by the owner of the public key hash
+func (out *TxOutput) IsLockedWithKey(pubKeyHash []byte) bool {
+	return bytes.Equal(out.PubKeyHash, pubKeyHash)
+}
@@ -0,0 +1,480 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+/*
+	The UTXO set is an index of every unspent transaction output of the chain, it lives in the
+	same badger database as the blocks, every key is the utxo prefix followed by the ID of a
+	transaction and the value is the list of the outputs of that transaction that are still unspent.
+	Instead of walking the whole chain to compute a balance we only have to read this index, and
+	every time a block is added the index is updated with the outputs that the block spends and creates.
+
+	Esp:
+
+	El set UTXO es un índice de todos los outputs no gastados de la cadena, vive en la misma base de
+	datos badger que los bloques, cada llave es el prefijo utxo seguido del ID de una transacción y el
+	valor es la lista de outputs de esa transacción que aun no han sido gastados.
+	En vez de recorrer toda la cadena para calcular un balance solo tenemos que leer este índice, y cada
+	vez que se añade un bloque el índice se actualiza con los outputs que el bloque gasta y crea.
+*/
+
+// collectionSize : max number of keys deleted or written in a single badger transaction
+const collectionSize = 100000
+
+// UTXOSet : the unspent transaction outputs index of a blockchain
+type UTXOSet struct {
+	Blockchain *BlockChain
+	Mempool    *Mempool // when it's set the outputs spent by pending transactions can't be spent again
+}
+
+// TxOutputs : unspent outputs of a transaction indexed by their position inside of the transaction
+type TxOutputs struct {
+	Outputs map[int]TxOutput
+}
+
+// FindSpendableOutputs : collect unspent outputs of the key until they add up to the amount
+func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int, error) {
+	unspentOuts := make(map[string][]int)
+	accumulated := 0
+	db := u.Blockchain.store
+
+	err := db.View(func(txn Txn) error {
+		it := txn.NewIterator(utxoPrefix, false)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid() && accumulated < amount; it.Next() {
+			k := it.Key()
+			v, err := it.Value()
+			if err != nil {
+				return err
+			}
+			k = bytes.TrimPrefix(k, utxoPrefix)
+			txID := hex.EncodeToString(k)
+			outs, err := DeserializeOutputs(v)
+			if err != nil {
+				return err
+			}
+
+			for _, outIdx := range outs.sortedIndexes() {
+				out := outs.Outputs[outIdx]
+				if u.Mempool != nil && u.Mempool.IsSpent(k, outIdx) {
+					continue
+				}
+				if out.IsLockedWithKey(pubKeyHash) && accumulated < amount {
+					accumulated += out.Value
+					unspentOuts[txID] = append(unspentOuts[txID], outIdx)
+				}
+			}
+		}
+		return nil
+	})
+
+	return accumulated, unspentOuts, err
+}
+
+// FindUTXO : all the unspent outputs locked to the key
+func (u UTXOSet) FindUTXO(pubKeyHash []byte) ([]TxOutput, error) {
+	var UTXOs []TxOutput
+
+	db := u.Blockchain.store
+
+	err := db.View(func(txn Txn) error {
+		it := txn.NewIterator(utxoPrefix, false)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			v, err := it.Value()
+			if err != nil {
+				return err
+			}
+			outs, err := DeserializeOutputs(v)
+			if err != nil {
+				return err
+			}
+
+			for _, outIdx := range outs.sortedIndexes() {
+				out := outs.Outputs[outIdx]
+				if out.IsLockedWithKey(pubKeyHash) {
+					UTXOs = append(UTXOs, out)
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return UTXOs, err
+}
+
+// UnspentOutput : an unspent output and where it is
+type UnspentOutput struct {
+	ID     []byte // ID of the transaction of the output
+	Out    int    // position of the output inside of the transaction
+	Output TxOutput
+}
+
+// FindUnspent : the unspent outputs locked to the key with the transaction that created each of them
+func (u UTXOSet) FindUnspent(pubKeyHash []byte) ([]UnspentOutput, error) {
+	var unspent []UnspentOutput
+
+	err := u.Blockchain.store.View(func(txn Txn) error {
+		it := txn.NewIterator(utxoPrefix, false)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			ID := bytes.TrimPrefix(it.Key(), utxoPrefix)
+			v, err := it.Value()
+			if err != nil {
+				return err
+			}
+			outs, err := DeserializeOutputs(v)
+			if err != nil {
+				return err
+			}
+
+			for _, outIdx := range outs.sortedIndexes() {
+				out := outs.Outputs[outIdx]
+				if out.IsLockedWithKey(pubKeyHash) {
+					unspent = append(unspent, UnspentOutput{ID, outIdx, out})
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return unspent, err
+}
+
+// FindOutput : the output out of the transaction with the ID, reports if it is unspent
+func (u UTXOSet) FindOutput(ID []byte, out int) (TxOutput, bool, error) {
+	var output TxOutput
+	var found bool
+
+	err := u.Blockchain.store.View(func(txn Txn) error {
+		v, err := txn.Get(append(append([]byte{}, utxoPrefix...), ID...))
+		if err == ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		outs, err := DeserializeOutputs(v)
+		if err != nil {
+			return err
+		}
+		output, found = outs.Outputs[out]
+
+		return nil
+	})
+
+	return output, found, err
+}
+
+// TotalValue : sum of every unspent output, the coins that are in circulation
+func (u UTXOSet) TotalValue() (int, error) {
+	total := 0
+
+	err := u.Blockchain.store.View(func(txn Txn) error {
+		it := txn.NewIterator(utxoPrefix, false)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			v, err := it.Value()
+			if err != nil {
+				return err
+			}
+			outs, err := DeserializeOutputs(v)
+			if err != nil {
+				return err
+			}
+			for _, out := range outs.Outputs {
+				total += out.Value
+			}
+		}
+
+		return nil
+	})
+
+	return total, err
+}
+
+// CountTransactions : number of transactions with at least one unspent output
+func (u UTXOSet) CountTransactions() (int, error) {
+	db := u.Blockchain.store
+	counter := 0
+
+	err := db.View(func(txn Txn) error {
+		it := txn.NewIterator(utxoPrefix, true) // only the keys are needed
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			counter++
+		}
+
+		return nil
+	})
+
+	return counter, err
+}
+
+// Reindex : rebuild the whole UTXO set walking the chain
+func (u UTXOSet) Reindex() error {
+	db := u.Blockchain.store
+
+	if err := u.DeleteByPrefix(utxoPrefix); err != nil {
+		return err
+	}
+
+	UTXO, err := u.Blockchain.FindUTXO()
+	if err != nil {
+		return err
+	}
+
+	keys := make([][]byte, 0, collectionSize)
+	values := make([][]byte, 0, collectionSize)
+
+	flush := func() error {
+		err := db.Update(func(txn Txn) error {
+			for i := range keys {
+				if err := txn.Set(keys[i], values[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		keys = keys[:0]
+		values = values[:0]
+
+		return err
+	}
+
+	for txId, outs := range UTXO {
+		key, err := hex.DecodeString(txId)
+		if err != nil {
+			return err
+		}
+		key = append(append([]byte{}, utxoPrefix...), key...)
+
+		value, err := outs.Serialize()
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+		values = append(values, value)
+
+		if len(keys) == collectionSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(keys) > 0 {
+		return flush()
+	}
+
+	return nil
+}
+
+// Update : apply the outputs spent and created by the block to the UTXO set
+func (u *UTXOSet) Update(block *Block) error {
+	db := u.Blockchain.store
+
+	return db.Update(func(txn Txn) error {
+		_, err := u.update(txn, block)
+		return err
+	})
+}
+
+// update : same as Update but inside of a badger transaction, so the block and the index are written together.
+// Returns the outputs spent by the block, they are needed to disconnect it
+func (u *UTXOSet) update(txn Txn, block *Block) ([]spentOutput, error) {
+	var spent []spentOutput
+
+	for _, tx := range block.Transactions {
+		if !tx.IsCoinbase() {
+			for _, in := range tx.Inputs { // remove the outputs spent by the inputs
+				inID := append(append([]byte{}, utxoPrefix...), in.ID...)
+				v, err := txn.Get(inID)
+				if err == ErrKeyNotFound {
+					return nil, fmt.Errorf("%w: output %d of %x is not unspent", ErrInvalidTx, in.Out, in.ID)
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				outs, err := DeserializeOutputs(v)
+				if err != nil {
+					return nil, err
+				}
+				output, ok := outs.Outputs[in.Out]
+				if !ok { // the output was spent by a previous input
+					return nil, fmt.Errorf("%w: output %d of %x is not unspent", ErrInvalidTx, in.Out, in.ID)
+				}
+				spent = append(spent, spentOutput{in.ID, in.Out, output})
+				delete(outs.Outputs, in.Out)
+
+				if len(outs.Outputs) == 0 { // every output of the transaction is spent
+					if err := txn.Delete(inID); err != nil {
+						return nil, err
+					}
+				} else {
+					value, err := outs.Serialize()
+					if err != nil {
+						return nil, err
+					}
+					if err := txn.Set(inID, value); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+
+		newOutputs := TxOutputs{make(map[int]TxOutput)} // every output of a new transaction is unspent
+		for outIdx, out := range tx.Outputs {
+			newOutputs.Outputs[outIdx] = out
+		}
+
+		value, err := newOutputs.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		txID := append(append([]byte{}, utxoPrefix...), tx.ID...)
+		if err := txn.Set(txID, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return spent, nil
+}
+
+// disconnect : undo update, the outputs created by the block are removed and the outputs it spent are unspent again
+func (u *UTXOSet) disconnect(txn Txn, block *Block, spent []spentOutput) error {
+	for _, tx := range block.Transactions { // the blocks after this one were disconnected first, so nothing spends these outputs
+		if err := txn.Delete(append(append([]byte{}, utxoPrefix...), tx.ID...)); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range spent {
+		key := append(append([]byte{}, utxoPrefix...), s.ID...)
+		outs := TxOutputs{make(map[int]TxOutput)}
+
+		v, err := txn.Get(key)
+		if err != nil && err != ErrKeyNotFound {
+			return err
+		}
+		if err == nil { // other outputs of the transaction are still unspent
+			if outs, err = DeserializeOutputs(v); err != nil {
+				return err
+			}
+		}
+		outs.Outputs[s.Out] = s.Output
+
+		value, err := outs.Serialize()
+		if err != nil {
+			return err
+		}
+		if err := txn.Set(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteByPrefix : delete every key of the database that starts with the prefix
+func (u *UTXOSet) DeleteByPrefix(prefix []byte) error {
+	deleteKeys := func(keysForDelete [][]byte) error {
+		if err := u.Blockchain.store.Update(func(txn Txn) error {
+			for _, key := range keysForDelete {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	return u.Blockchain.store.View(func(txn Txn) error {
+		it := txn.NewIterator(prefix, true)
+		defer it.Close()
+
+		keysForDelete := make([][]byte, 0, collectionSize)
+		keysCollected := 0
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := it.Key()
+			keysForDelete = append(keysForDelete, key)
+			keysCollected++
+			if keysCollected == collectionSize {
+				if err := deleteKeys(keysForDelete); err != nil {
+					return err
+				}
+				keysForDelete = make([][]byte, 0, collectionSize)
+				keysCollected = 0
+			}
+		}
+		if keysCollected > 0 {
+			if err := deleteKeys(keysForDelete); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Serialize : binary encoding of the outputs sorted by index, described in encoding.go
+func (outs TxOutputs) Serialize() ([]byte, error) {
+	e := newEncoder()
+	e.writeCount(len(outs.Outputs))
+	for _, outIdx := range outs.sortedIndexes() {
+		e.writeInt(int64(outIdx))
+		e.writeOutput(outs.Outputs[outIdx])
+	}
+
+	return e.buffer.Bytes(), nil
+}
+
+// DeserializeOutputs : decode outputs encoded with TxOutputs.Serialize or with gob by older versions
+func DeserializeOutputs(data []byte) (TxOutputs, error) {
+	var outputs TxOutputs
+
+	if isLegacy(data) {
+		err := decodeLegacy(data, &outputs)
+		return outputs, err
+	}
+
+	d := newDecoder(data)
+	n := d.readCount()
+	outputs.Outputs = make(map[int]TxOutput, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		outIdx := d.readInt()
+		if _, ok := outputs.Outputs[outIdx]; ok {
+			d.fail("output %d repeated", outIdx)
+		}
+		outputs.Outputs[outIdx] = d.readOutput()
+	}
+
+	return outputs, d.finish()
+}
+
+// sortedIndexes : indexes of the outputs in the order they have inside of the transaction
+func (outs TxOutputs) sortedIndexes() []int {
+	indexes := make([]int, 0, len(outs.Outputs))
+	for outIdx := range outs.Outputs {
+		indexes = append(indexes, outIdx)
+	}
+	sort.Ints(indexes)
+
+	return indexes
+}
@@ -0,0 +1,259 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+/*
+	Verify replays the main chain from the genesis block enforcing every rule of the chain, it
+	doesn't trust anything that was checked when the blocks were added. The unspent outputs are
+	kept in memory while the blocks are replayed, so every input must spend an output that exists
+	and that no previous block spent. To verify only the last blocks the UTXO set stored in the
+	database is rolled back to the first of them with the undo data of the blocks, and the replay
+	starts there.
+
+	Esp:
+
+	Verify vuelve a reproducir la cadena principal desde el bloque génesis aplicando cada regla de
+	la cadena, no confía en nada de lo que se comprobó cuando los bloques fueron añadidos. Los
+	outputs no gastados se mantienen en memoria mientras se reproducen los bloques, asi cada input
+	debe gastar un output que existe y que ningún bloque anterior gastó. Para verificar solo los
+	últimos bloques el set UTXO guardado en la base de datos se retrocede hasta el primero de ellos
+	con los datos de deshacer de los bloques, y la reproducción empieza ahí.
+*/
+
+// VerifyError : the first block of the chain that breaks the rules
+type VerifyError struct {
+	Height int
+	Hash   []byte
+	Err    error // the rule that is broken, it wraps ErrInvalidBlock or ErrInvalidTx
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("block %x at height %d: %v", e.Hash, e.Height, e.Err)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// chainState : the unspent outputs and the transactions seen while the chain is replayed
+type chainState struct {
+	chain *BlockChain
+	utxo  map[string]TxOutput    // unspent outputs by outpoint
+	txs   map[string]Transaction // replayed transactions by ID, the older ones are looked up in the chain
+}
+
+// Verify : replay the last blocks of the main chain, or the whole chain if last is 0, enforcing the rules of the chain.
+// Returns the number of verified blocks, the first invalid block is reported with a *VerifyError
+func (chain *BlockChain) Verify(last int) (int, error) {
+	var hashes [][]byte // from the tip back to the first block to verify
+
+	iter := chain.Iterator()
+	for last <= 0 || len(hashes) < last {
+		hash := iter.CurrentHash
+		block, err := iter.Next()
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.Equal(block.Hash, hash) {
+			return 0, &VerifyError{block.Height, hash, fmt.Errorf("%w: stored with the hash %x but its hash is %x", ErrInvalidBlock, hash, block.Hash)}
+		}
+		hashes = append(hashes, hash)
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	state := &chainState{chain, make(map[string]TxOutput), make(map[string]Transaction)}
+	if len(hashes) > 0 {
+		first, err := chain.GetBlock(hashes[len(hashes)-1])
+		if err != nil {
+			return 0, err
+		}
+		if len(first.PrevHash) != 0 { // the replay starts in the middle of the chain
+			if err := state.rollback(hashes); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var prev *Block
+	for i := len(hashes) - 1; i >= 0; i-- {
+		block, err := chain.GetBlock(hashes[i])
+		if err != nil {
+			return 0, err
+		}
+
+		if err := state.verifyBlock(&block, prev); err != nil {
+			return len(hashes) - 1 - i, &VerifyError{block.Height, block.Hash, err}
+		}
+		indexed, err := chain.BlockHashAt(block.Height)
+		if err != nil && !errors.Is(err, ErrBlockNotFound) {
+			return 0, err
+		}
+		if !bytes.Equal(indexed, block.Hash) {
+			return len(hashes) - 1 - i, &VerifyError{block.Height, block.Hash, fmt.Errorf("%w: the height index has the block %x at its height", ErrInvalidBlock, indexed)}
+		}
+		prev = &block
+	}
+
+	return len(hashes), nil
+}
+
+// rollback : load the UTXO set of the database and undo the blocks with the hashes, from the newest to the oldest
+func (state *chainState) rollback(hashes [][]byte) error {
+	return state.chain.store.View(func(txn Txn) error {
+		it := txn.NewIterator(utxoPrefix, false)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			ID := bytes.TrimPrefix(it.Key(), utxoPrefix)
+			v, err := it.Value()
+			if err != nil {
+				return err
+			}
+			outs, err := DeserializeOutputs(v)
+			if err != nil {
+				return err
+			}
+			for outIdx, out := range outs.Outputs {
+				state.utxo[outpoint(ID, outIdx)] = out
+			}
+		}
+
+		for _, hash := range hashes {
+			block, err := state.chain.GetBlock(hash)
+			if err != nil {
+				return err
+			}
+			for _, tx := range block.Transactions {
+				for outIdx := range tx.Outputs {
+					delete(state.utxo, outpoint(tx.ID, outIdx))
+				}
+			}
+
+			spent, err := state.chain.undoData(txn, &block)
+			if err != nil {
+				return err
+			}
+			for _, s := range spent {
+				state.utxo[outpoint(s.ID, s.Out)] = s.Output
+			}
+		}
+
+		return nil
+	})
+}
+
+// verifyBlock : check the block on top of prev, or on top of the stored chain when prev is nil, and apply it to the state
+func (state *chainState) verifyBlock(block *Block, prev *Block) error {
+	if prev != nil && !bytes.Equal(block.PrevHash, prev.Hash) {
+		return fmt.Errorf("%w: previous hash %x, expected %x", ErrInvalidBlock, block.PrevHash, prev.Hash)
+	}
+	if len(block.Transactions) == 0 {
+		return fmt.Errorf("%w: no transactions", ErrInvalidBlock)
+	}
+	if root := block.HashTransactions(); !bytes.Equal(block.MerkleRoot, root) {
+		return fmt.Errorf("%w: Merkle root %x, the transactions give %x", ErrInvalidBlock, block.MerkleRoot, root)
+	}
+	if hash := block.BlockHeader.Hash(); !bytes.Equal(block.Hash, hash) {
+		return fmt.Errorf("%w: hash %x, the header gives %x", ErrInvalidBlock, block.Hash, hash)
+	}
+	if err := state.chain.ValidateHeader(block); err != nil { // proof of work, version, height, difficulty and timestamps
+		return err
+	}
+
+	fees := 0
+	var coinbase *Transaction
+
+	for i, tx := range block.Transactions {
+		if err := tx.checkID(); err != nil {
+			return err
+		}
+
+		if tx.IsCoinbase() {
+			if i != 0 {
+				return fmt.Errorf("%w: coinbase %x at position %d", ErrInvalidBlock, tx.ID, i)
+			}
+			coinbase = tx
+		} else {
+			fee, err := state.verifyTransaction(tx, block.PrevHash)
+			if err != nil {
+				return err
+			}
+			var ok bool
+			if fees, ok = addValue(fees, fee); !ok {
+				return fmt.Errorf("%w: the fees of the block overflow", ErrInvalidBlock)
+			}
+		}
+	}
+
+	for _, tx := range block.Transactions { // a transaction can't spend the outputs of its own block
+		for outIdx, out := range tx.Outputs {
+			state.utxo[outpoint(tx.ID, outIdx)] = out
+		}
+		state.txs[hex.EncodeToString(tx.ID)] = *tx
+	}
+
+	if coinbase != nil {
+		return checkCoinbase(coinbase, state.chain.Params.Subsidy(block.Height), fees)
+	}
+
+	return nil
+}
+
+// verifyTransaction : check that the transaction spends unspent outputs with valid signatures and doesn't
+// create coins, the outputs it spends are removed from the state. Returns the fee of the transaction
+func (state *chainState) verifyTransaction(tx *Transaction, tip []byte) (int, error) {
+	if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		return 0, fmt.Errorf("%w: %x has no inputs or no outputs", ErrInvalidTx, tx.ID)
+	}
+
+	prevTXs := make(map[string]Transaction)
+	inputs := 0
+
+	for _, in := range tx.Inputs {
+		op := outpoint(in.ID, in.Out)
+		out, ok := state.utxo[op]
+		if !ok { // it never existed or a previous input already spent it
+			return 0, fmt.Errorf("%w: %x spends output %d of %x that is not unspent", ErrInvalidTx, tx.ID, in.Out, in.ID)
+		}
+		delete(state.utxo, op)
+		if inputs, ok = addValue(inputs, out.Value); !ok {
+			return 0, fmt.Errorf("%w: the inputs of %x overflow", ErrInvalidTx, tx.ID)
+		}
+
+		prevID := hex.EncodeToString(in.ID)
+		if _, ok := prevTXs[prevID]; ok {
+			continue
+		}
+		prevTX, ok := state.txs[prevID]
+		if !ok { // a transaction of a block before the replay
+			found, err := state.chain.findTransaction(in.ID, tip)
+			if err != nil {
+				return 0, err
+			}
+			prevTX = found
+		}
+		prevTXs[prevID] = prevTX
+	}
+
+	outputs, err := tx.outputsValue()
+	if err != nil {
+		return 0, err
+	}
+	if outputs > inputs {
+		return 0, fmt.Errorf("%w: %x spends %d but its outputs add up to %d", ErrInvalidTx, tx.ID, inputs, outputs)
+	}
+
+	if !tx.Verify(prevTXs) {
+		return 0, fmt.Errorf("%w: %x has an invalid signature", ErrInvalidTx, tx.ID)
+	}
+
+	return inputs - outputs, nil
+}
@@ -0,0 +1,1366 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Dieg0Code/Blockchain.go/blockchain"
+	"github.com/Dieg0Code/Blockchain.go/explorer"
+	"github.com/Dieg0Code/Blockchain.go/network"
+	"github.com/Dieg0Code/Blockchain.go/rpc"
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+// Environment variables read when the global flags are not set
+const (
+	DataDirEnv   = "BLOCKCHAIN_DATADIR"
+	NetworkEnv   = "BLOCKCHAIN_NETWORK"
+	RPCEnv       = "BLOCKCHAIN_RPC"
+	RPCCookieEnv = "BLOCKCHAIN_RPCCOOKIE"
+	TxIndexEnv   = "BLOCKCHAIN_TXINDEX"
+	AddrIndexEnv = "BLOCKCHAIN_ADDRINDEX"
+)
+
+// CommandLine : CLI struct
+type CommandLine struct {
+	options blockchain.Options // where the chain and the wallets are stored, from the global flags
+	client  *rpc.Client        // when it's set the commands are sent to a running daemon instead of opening the database
+}
+
+// rpcCommands : the commands that can be sent to a daemon with -rpc
+var rpcCommands = map[string]bool{
+	"getbalance":    true,
+	"getblock":      true,
+	"getblockcount": true,
+	"gettx":         true,
+	"history":       true,
+	"mempool":       true,
+	"printchain":    true,
+	"provetx":       true,
+	"rpc":           true,
+	"send":          true,
+	"supply":        true,
+}
+
+/*
+	Every command returns an error instead of stopping the program, Run prints the error and
+	translates it to the exit code of the process, so scripts can tell what went wrong.
+
+	Esp:
+
+	Cada comando retorna un error en vez de detener el programa, Run imprime el error y lo
+	traduce al código de salida del proceso, asi los scripts pueden saber que salio mal.
+*/
+
+// Exit codes of the process
+const (
+	ExitOK               = 0
+	ExitError            = 1 // any error without a more specific code
+	ExitUsage            = 2 // wrong command or flags
+	ExitNoChain          = 3
+	ExitChainExists      = 4
+	ExitInsufficientFund = 5
+	ExitInvalidAddress   = 6 // the address is not valid or its wallet is not in the wallet file
+	ExitInvalid          = 7 // a transaction, block or proof breaks the rules of the chain
+	ExitNotFound         = 8 // the block or transaction does not exist
+	ExitSchema           = 9 // the database has to be migrated or was written by a newer version
+)
+
+var (
+	errUsage        = errors.New("wrong usage")
+	errInvalidProof = errors.New("invalid proof")
+)
+
+// exitCode : exit code of the process for the error returned by a command
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, errUsage):
+		return ExitUsage
+	case errors.Is(err, blockchain.ErrNoChain), errors.Is(err, blockchain.ErrUnknownNetwork):
+		return ExitNoChain
+	case errors.Is(err, blockchain.ErrChainExists):
+		return ExitChainExists
+	case errors.Is(err, blockchain.ErrSchemaOutdated), errors.Is(err, blockchain.ErrSchemaTooNew):
+		return ExitSchema
+	case errors.Is(err, blockchain.ErrInsufficientFunds):
+		return ExitInsufficientFund
+	case errors.Is(err, wallet.ErrInvalidAddress), errors.Is(err, wallet.ErrWalletNotFound):
+		return ExitInvalidAddress
+	case errors.Is(err, blockchain.ErrInvalidTx), errors.Is(err, blockchain.ErrDoubleSpend), errors.Is(err, blockchain.ErrKnownTx),
+		errors.Is(err, blockchain.ErrInvalidBlock), errors.Is(err, blockchain.ErrInvalidBootstrap), errors.Is(err, errInvalidProof):
+		return ExitInvalid
+	case errors.Is(err, blockchain.ErrBlockNotFound), errors.Is(err, blockchain.ErrTxNotFound):
+		return ExitNotFound
+	default:
+		return ExitError
+	}
+}
+
+func (cli *CommandLine) printUsage() {
+	fmt.Println("Usage: [-datadir DIR] [-network NAME] [-rpc HOST:PORT [-rpccookie FILE]] COMMAND")
+	fmt.Printf(" -datadir DIR - directory with the data of every network, $%s or %s by default\n", DataDirEnv, blockchain.DefaultDataDir)
+	fmt.Printf(" -network NAME - network of the chain (main, test), $%s or %s by default\n", NetworkEnv, blockchain.DefaultNetwork)
+	fmt.Printf(" -rpc HOST:PORT - send the commands that read the chain, getbalance, send and rpc to a running daemon instead of opening the database, $%s by default\n", RPCEnv)
+	fmt.Printf(" -rpccookie FILE - cookie file with the token of the daemon, $%s or the .cookie file of the network by default\n", RPCCookieEnv)
+	fmt.Printf(" -txindex - index the transactions of the chain by ID, once built the index is kept, $%s by default\n", TxIndexEnv)
+	fmt.Printf(" -addrindex - index the transactions of the chain by address, once built the index is kept, $%s by default\n", AddrIndexEnv)
+	fmt.Println("Commands:")
+	fmt.Println(" getbalance -address ADDRESS - get the balance for the address")
+	fmt.Println(" history -address ADDRESS [-limit N] [-offset N] - Prints the transactions of the address from the newest, with the coins received and sent, the counterparties and the balance after each one, it's faster with -addrindex")
+	fmt.Println(" createblockchain -address ADDRESS creates a blockchain and sends genesis reward address")
+	fmt.Println(" printchain [-from HEIGHT] [-to HEIGHT] - Prints the blocks in the chain, or the ones between the two heights, from the newest to the oldest")
+	fmt.Println(" getblock -hash HASH | -height HEIGHT - Prints the block with the hash, or the block of the main chain at the height")
+	fmt.Println(" getblockcount - Prints the height of the last block, the number of blocks after the genesis block")
+	fmt.Println(" gettx -id TXID - Prints the transaction, its block and its confirmations, it's found faster with -txindex")
+	fmt.Println(" send -from FROM -to TO -amount AMOUNT [-fee FEE] [-threads N] [-quiet] [-pending | -node HOST:PORT] - Send amount of coins paying the fee to the miner, mining the block with N threads, keeping the transaction in the mempool or sending it to a node")
+	fmt.Println(" mine -address ADDRESS [-max N] [-threads N] [-quiet] - Mine a block with the transactions of the mempool that pay the highest fees, the reward and the fees go to the address")
+	fmt.Println(" mempool - Prints the transactions waiting to be mined")
+	fmt.Println(" supply - Prints the coins in circulation and when the subsidy is halved next")
+	fmt.Println(" createwallet - Creates a new Wallet")
+	fmt.Println(" listaddresses - Lists the addresses in our wallet file")
+	fmt.Println(" reindexutxo - Rebuilds the UTXO set")
+	fmt.Println(" migrate [-dryrun] - Upgrades the database to the current schema after copying it next to the original, -dryrun only reports what would change")
+	fmt.Println(" exportchain -out FILE - Writes the blocks of the main chain to a bootstrap file that importchain can read on another machine")
+	fmt.Println(" importchain -in FILE - Validates the blocks of a bootstrap file and adds them to the chain, creating it when the network has none, and stops at the first invalid block")
+	fmt.Println(" verifychain [-last N] - Replays the chain, or its last N blocks, checking every rule and reports the first invalid block")
+	fmt.Println(" provetx -txid TXID -block HASH - Prints a Merkle proof that the transaction is inside of the block")
+	fmt.Println(" verifyproof -proof FILE - Verifies offline a proof printed by provetx, the block must have a difficulty allowed by the network")
+	fmt.Println(" startnode -port PORT [-host HOST] [-peers HOST:PORT,...] [-miner ADDRESS] [-mintxs N] [-threads N] [-rpcport PORT] [-rpchost HOST] [-explorerport PORT] - Start a node that syncs the chain with its peers, mining the transactions it receives if a miner address is set, serving JSON-RPC on 127.0.0.1 or the RPC host if an RPC port is set and a web block explorer if an explorer port is set")
+	fmt.Println(" rpc -method METHOD [-params JSON] - Call a method of the daemon set with -rpc and print its result")
+}
+
+func (cli *CommandLine) validateArgs(args []string) error {
+	if len(args) < 1 {
+		cli.printUsage()
+		return errUsage
+	}
+	return nil
+}
+
+func (cli *CommandLine) printChain(from, to int) error {
+	if cli.client != nil {
+		var block *blockchain.Block
+		var valid bool
+		var err error
+		if to >= 0 {
+			block, valid, err = cli.remoteBlock("getblockbyheight", rpc.HeightParams{Height: &to})
+		} else {
+			var hash string
+			if err := cli.client.Call("getbestblockhash", nil, &hash); err != nil {
+				return err
+			}
+			block, valid, err = cli.remoteBlock("getblock", rpc.BlockParams{Hash: hash})
+		}
+
+		for err == nil {
+			printBlock(block, valid)
+
+			if len(block.PrevHash) == 0 || block.Height <= from {
+				break
+			}
+			block, valid, err = cli.remoteBlock("getblock", rpc.BlockParams{Hash: hex.EncodeToString(block.PrevHash)})
+		}
+
+		return err
+	}
+
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+	iter := chain.Iterator()
+
+	if to >= 0 { // start at the last block of the range instead of the tip
+		if iter.CurrentHash, err = chain.BlockHashAt(to); err != nil {
+			return err
+		}
+	}
+
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return err
+		}
+
+		printBlock(block, chain.ValidateHeader(block) == nil)
+
+		if len(block.PrevHash) == 0 || block.Height <= from {
+			break
+		}
+	}
+
+	return nil
+}
+
+// remoteBlock : the block returned by the method of the daemon and whether its header is valid
+func (cli *CommandLine) remoteBlock(method string, params interface{}) (*blockchain.Block, bool, error) {
+	var info rpc.BlockInfo
+	if err := cli.client.Call(method, params, &info); err != nil {
+		return nil, false, err
+	}
+	block, err := info.Decode()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return block, info.ValidHeader, nil
+}
+
+// printBlock : print the header and the transactions of the block
+func printBlock(block *blockchain.Block, validHeader bool) {
+	fmt.Printf("Height: %d\n", block.Height)
+	fmt.Printf("Version: %d\n", block.Version)
+	fmt.Printf("Timestamp: %s\n", time.Unix(block.Timestamp, 0).UTC().Format(time.RFC3339))
+	fmt.Printf("Prev. hash: %x\n", block.PrevHash)
+	fmt.Printf("Hash: %x\n", block.Hash)
+	fmt.Printf("Merkle root: %x\n", block.MerkleRoot)
+	fmt.Printf("Difficulty: %d\n", block.Difficulty)
+	fmt.Printf("Nonce: %d\n", block.Nonce)
+	pow := blockchain.NewProof(block)
+	fmt.Printf("PoW: %s\n", strconv.FormatBool(pow.Validate()))
+	fmt.Printf("Valid header: %s\n", strconv.FormatBool(validHeader))
+	for _, tx := range block.Transactions {
+		fmt.Println(tx)
+	}
+	fmt.Println()
+}
+
+func (cli *CommandLine) getBlock(hash string, height int) error {
+	blockHash, err := hex.DecodeString(hash)
+	if err != nil {
+		return fmt.Errorf("%w: hash: %v", errUsage, err)
+	}
+
+	if cli.client != nil {
+		var block *blockchain.Block
+		var valid bool
+		if hash != "" {
+			block, valid, err = cli.remoteBlock("getblock", rpc.BlockParams{Hash: hash})
+		} else {
+			block, valid, err = cli.remoteBlock("getblockbyheight", rpc.HeightParams{Height: &height})
+		}
+		if err != nil {
+			return err
+		}
+		printBlock(block, valid)
+
+		return nil
+	}
+
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+
+	var block blockchain.Block
+	if hash != "" {
+		block, err = chain.GetBlock(blockHash)
+	} else {
+		block, err = chain.GetBlockByHeight(height)
+	}
+	if err != nil {
+		return err
+	}
+
+	printBlock(&block, chain.ValidateHeader(&block) == nil)
+
+	return nil
+}
+
+func (cli *CommandLine) getBlockCount() error {
+	if cli.client != nil {
+		var info rpc.ChainInfo
+		if err := cli.client.Call("getchaininfo", nil, &info); err != nil {
+			return err
+		}
+		fmt.Println(info.Height)
+
+		return nil
+	}
+
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+
+	height, err := chain.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	fmt.Println(height)
+
+	return nil
+}
+
+func (cli *CommandLine) getTx(txID string) error {
+	ID, err := hex.DecodeString(txID)
+	if err != nil {
+		return fmt.Errorf("%w: id: %v", errUsage, err)
+	}
+
+	if cli.client != nil {
+		var info rpc.TransactionInfo
+		if err := cli.client.Call("gettransaction", rpc.TxParams{TxID: txID}, &info); err != nil {
+			return err
+		}
+		tx, err := info.Transaction.Decode()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(tx)
+		if info.Height < 0 {
+			fmt.Println("Block: none, the transaction is in the mempool")
+		} else {
+			fmt.Printf("Block: %s\n", info.BlockHash)
+			fmt.Printf("Height: %d\n", info.Height)
+		}
+		fmt.Printf("Confirmations: %d\n", info.Confirmations)
+
+		return nil
+	}
+
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+
+	tx, block, err := chain.FindTransactionBlock(ID)
+	if errors.Is(err, blockchain.ErrTxNotFound) { // it can still be waiting to be mined
+		pool, poolErr := blockchain.NewMempool(chain)
+		if poolErr != nil {
+			return poolErr
+		}
+		if pending, ok := pool.Get(ID); ok {
+			fmt.Println(pending)
+			fmt.Println("Block: none, the transaction is in the mempool")
+			fmt.Println("Confirmations: 0")
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	best, err := chain.GetBestHeight()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(tx)
+	fmt.Printf("Block: %x\n", block.Hash)
+	fmt.Printf("Height: %d\n", block.Height)
+	fmt.Printf("Confirmations: %d\n", best-block.Height+1)
+
+	return nil
+}
+
+func (cli *CommandLine) listAddresses() error {
+	wallets, err := wallet.CreateWallets(cli.options.WalletFile())
+	if err != nil {
+		return err
+	}
+	addresses := wallets.GetAllAddresses()
+
+	for _, address := range addresses {
+		fmt.Println(address)
+	}
+
+	return nil
+}
+
+func (cli *CommandLine) createWallet() error {
+	wallets, err := wallet.CreateWallets(cli.options.WalletFile())
+	if err != nil {
+		return err
+	}
+	address, err := wallets.AddWallet()
+	if err != nil {
+		return err
+	}
+	if err := wallets.SaveFile(); err != nil {
+		return err
+	}
+
+	fmt.Printf("New address is: %s\n", address)
+
+	return nil
+}
+
+func (cli *CommandLine) createBlockChain(address string) error {
+	if !wallet.ValidateAddress(address) {
+		return fmt.Errorf("%w %q", wallet.ErrInvalidAddress, address)
+	}
+	chain, err := blockchain.InitBlockChain(address, cli.options) // the addres would be the person who mines the genesis block
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+
+	fmt.Println("Finished")
+
+	return nil
+}
+
+func (cli *CommandLine) reindexUTXO() error {
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+	if err := UTXOSet.Reindex(); err != nil {
+		return err
+	}
+
+	count, err := UTXOSet.CountTransactions()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Done! There are %d transactions in the UTXO set.\n", count)
+
+	return nil
+}
+
+func (cli *CommandLine) verifyChain(last int) error {
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+
+	verified, err := chain.Verify(last)
+	var verifyErr *blockchain.VerifyError
+	if errors.As(err, &verifyErr) {
+		fmt.Printf("Verified %d blocks\n", verified)
+		fmt.Printf("Invalid block %x at height %d\n", verifyErr.Hash, verifyErr.Height)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Verified %d blocks, the chain is valid\n", verified)
+
+	return nil
+}
+
+func (cli *CommandLine) migrate(dryRun bool) error {
+	report, err := blockchain.MigrateDatabase(cli.options, dryRun)
+	if err != nil {
+		return err
+	}
+	if report.From == report.To {
+		fmt.Printf("The database already has schema %d\n", report.To)
+		return nil
+	}
+
+	kinds := make([]string, 0, len(report.Keys))
+	for kind := range report.Keys {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, kind := range kinds {
+		fmt.Fprintf(w, " %s\t%d keys\n", kind, report.Keys[kind])
+	}
+	w.Flush()
+	fmt.Printf("%d records re-encoded from gob, %d keys dropped\n", report.Reencoded, report.Dropped)
+
+	if dryRun {
+		fmt.Printf("Dry run, the database would be migrated from schema %d to schema %d\n", report.From, report.To)
+		return nil
+	}
+	fmt.Printf("Backup of the database in %s\n", report.Backup)
+	fmt.Printf("Migrated the database from schema %d to schema %d\n", report.From, report.To)
+
+	return nil
+}
+
+func (cli *CommandLine) exportChain(outFile string) error {
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+
+	file, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+
+	count, err := chain.ExportChain(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outFile) // half a file would only fail later, on the other machine
+		return err
+	}
+
+	fmt.Printf("Exported %d blocks to %s\n", count, outFile)
+
+	return nil
+}
+
+func (cli *CommandLine) importChain(inFile string) error {
+	file, err := os.Open(inFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stats, err := blockchain.ImportChain(file, cli.options, func(stats blockchain.ImportStats) {
+		fmt.Printf("\rImporting: block %d of %d, height %d", stats.Read, stats.Total, stats.Height)
+	})
+	if stats.Read > 0 { // the progress line is ended and the blocks handled before an error are reported
+		fmt.Println()
+		var verifyErr *blockchain.VerifyError
+		if errors.As(err, &verifyErr) {
+			fmt.Printf("Invalid block %x at height %d\n", verifyErr.Hash, verifyErr.Height)
+		}
+		fmt.Printf("Imported %d blocks, %d were already stored\n", stats.Imported, stats.Known)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("The chain has %d blocks, the tip is at height %d\n", stats.Height+1, stats.Height)
+
+	return nil
+}
+
+func (cli *CommandLine) proveTx(txID, blockHash string) error {
+	id, err := hex.DecodeString(txID)
+	if err != nil {
+		return fmt.Errorf("%w: txid: %v", errUsage, err)
+	}
+	hash, err := hex.DecodeString(blockHash)
+	if err != nil {
+		return fmt.Errorf("%w: block: %v", errUsage, err)
+	}
+
+	var proof *blockchain.MerkleProof
+	if cli.client != nil {
+		if err := cli.client.Call("gettxproof", rpc.ProofParams{TxID: txID, Block: blockHash}, &proof); err != nil {
+			return err
+		}
+	} else {
+		chain, err := blockchain.ContinueBlockChain(cli.options)
+		if err != nil {
+			return err
+		}
+		defer chain.Close()
+
+		if proof, err = chain.ProveTransaction(id, hash); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+
+	return nil
+}
+
+func (cli *CommandLine) verifyProof(proofFile string) error {
+	data, err := ioutil.ReadFile(proofFile)
+	if err != nil {
+		return err
+	}
+
+	var proof blockchain.MerkleProof
+	if err := json.Unmarshal(data, &proof); err != nil {
+		return err
+	}
+	params, err := cli.options.Params() // the proof is checked against the difficulty limits of the network
+	if err != nil {
+		return err
+	}
+
+	valid := proof.Verify(params)
+	fmt.Printf("Valid: %s\n", strconv.FormatBool(valid))
+	if !valid {
+		return errInvalidProof
+	}
+
+	return nil
+}
+
+func (cli *CommandLine) getBalance(address string) error {
+	pubKeyHash, err := wallet.PubKeyHashFromAddress(address)
+	if err != nil {
+		return err
+	}
+
+	if cli.client != nil {
+		var balance rpc.Balance
+		if err := cli.client.Call("getbalance", rpc.AddressParams{Address: address}, &balance); err != nil {
+			return err
+		}
+		fmt.Printf("Balance of %s: %d\n", address, balance.Balance)
+
+		return nil
+	}
+
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+
+	balance := 0
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+	UTXOs, err := UTXOSet.FindUTXO(pubKeyHash)
+	if err != nil {
+		return err
+	}
+
+	for _, out := range UTXOs {
+		balance += out.Value
+	}
+
+	fmt.Printf("Balance of %s: %d\n", address, balance)
+
+	return nil
+}
+
+func (cli *CommandLine) history(address string, limit, offset int) error {
+	pubKeyHash, err := wallet.PubKeyHashFromAddress(address)
+	if err != nil {
+		return err
+	}
+
+	var history []blockchain.AddressTx
+	if cli.client != nil {
+		var views []rpc.AddressTx
+		if err := cli.client.Call("gethistory", rpc.HistoryParams{Address: address, Offset: offset, Limit: limit}, &views); err != nil {
+			return err
+		}
+		for _, view := range views {
+			entry, err := view.Decode()
+			if err != nil {
+				return err
+			}
+			history = append(history, entry)
+		}
+	} else {
+		chain, err := blockchain.ContinueBlockChain(cli.options)
+		if err != nil {
+			return err
+		}
+		defer chain.Close()
+
+		if history, err = chain.AddressHistory(pubKeyHash, offset, limit); err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HEIGHT\tTIME\tTRANSACTION\tRECEIVED\tSENT\tBALANCE\tCOUNTERPARTIES")
+	for _, entry := range history {
+		counterparties := strings.Join(entry.Counterparties, ",")
+		if entry.Coinbase {
+			counterparties = "coinbase"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%x\t%d\t%d\t%d\t%s %s\n",
+			entry.Height,
+			time.Unix(entry.Timestamp, 0).UTC().Format(time.RFC3339),
+			entry.TxID,
+			entry.Received,
+			entry.Sent,
+			entry.Balance,
+			directionArrow(entry),
+			counterparties)
+	}
+
+	return w.Flush()
+}
+
+// directionArrow : "<-" when the address receives coins from the counterparties, "->" when it sends them
+func directionArrow(entry blockchain.AddressTx) string {
+	if entry.Direction() == "received" {
+		return "<-"
+	}
+	return "->"
+}
+
+func (cli *CommandLine) supply() error {
+	params, err := cli.options.Params()
+	if err != nil {
+		return err
+	}
+
+	var height, circulating int
+	if cli.client != nil {
+		var info rpc.ChainInfo
+		if err := cli.client.Call("getchaininfo", nil, &info); err != nil {
+			return err
+		}
+		height, circulating = info.Height, info.Supply
+	} else {
+		chain, err := blockchain.ContinueBlockChain(cli.options)
+		if err != nil {
+			return err
+		}
+		defer chain.Close()
+
+		if height, err = chain.GetBestHeight(); err != nil {
+			return err
+		}
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		if circulating, err = UTXOSet.TotalValue(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Height: %d\n", height)
+	fmt.Printf("Circulating supply: %d\n", circulating)
+	fmt.Printf("Issued by the schedule: %d\n", params.Supply(height))
+	if params.MaxSupply > 0 {
+		fmt.Printf("Max supply: %d\n", params.MaxSupply)
+	}
+	fmt.Printf("Next block subsidy: %d\n", params.Subsidy(height+1))
+	if next := params.NextHalving(height); next > 0 && params.Subsidy(next) > 0 {
+		fmt.Printf("Next halving: height %d, subsidy %d\n", next, params.Subsidy(next))
+	} else {
+		fmt.Println("Next halving: none")
+	}
+
+	return nil
+}
+
+// miner : mining options for the threads and quiet flags, the hash rate is printed unless quiet is set
+func miner(threads int, quiet bool) blockchain.MinerOptions {
+	opts := blockchain.MinerOptions{Threads: threads}
+	if !quiet {
+		opts.OnStats = func(stats blockchain.MinerStats) {
+			fmt.Printf("\rMining: %d hashes in %s, %.0f H/s", stats.Hashes, stats.Elapsed.Round(time.Millisecond), stats.HashRate)
+			if stats.Done {
+				fmt.Println()
+			}
+		}
+	}
+
+	return opts
+}
+
+func (cli *CommandLine) send(from, to string, amount, fee int, pending bool, node string, opts blockchain.MinerOptions) error { // allow us to send tokens from one account to another
+	if !wallet.ValidateAddress(to) {
+		return fmt.Errorf("%w %q", wallet.ErrInvalidAddress, to)
+	}
+	if !wallet.ValidateAddress(from) {
+		return fmt.Errorf("%w %q", wallet.ErrInvalidAddress, from)
+	}
+
+	if cli.client != nil { // the daemon signs the transaction with its wallet file and relays it
+		var txID string
+		if err := cli.client.Call("send", rpc.SendParams{From: from, To: to, Amount: amount, Fee: fee}, &txID); err != nil {
+			return err
+		}
+		fmt.Printf("Transaction %s sent to %s\n", txID, cli.client.Address)
+
+		return nil
+	}
+
+	wallets, err := wallet.CreateWallets(cli.options.WalletFile())
+	if err != nil {
+		return err
+	}
+	w, err := wallets.GetWallet(from)
+	if err != nil {
+		return err
+	}
+
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+	chain.Miner = opts
+
+	pool, err := blockchain.NewMempool(chain)
+	if err != nil {
+		return err
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain, Mempool: pool}     // the outputs of our pending transactions can't be spent again
+	tx, err := blockchain.NewTransaction(&w, to, amount, fee, &UTXOSet) // create a new transaction
+	if err != nil {
+		return err
+	}
+
+	if node != "" { // the node relays the transaction and the miners of the network put it in a block
+		if err := network.SendTx(node, tx); err != nil {
+			return err
+		}
+		fmt.Printf("Transaction %x sent to %s\n", tx.ID, node)
+
+		return nil
+	}
+
+	if pending {
+		if err := pool.Add(tx); err != nil {
+			return err
+		}
+		fmt.Printf("Transaction %x added to the mempool\n", tx.ID)
+
+		return nil
+	}
+
+	txs := []*blockchain.Transaction{tx}
+	cbTx, err := chain.NewCoinbase(from, txs) // the sender mines the block, so the reward and the fee go to the sender
+	if err != nil {
+		return err
+	}
+
+	block, err := chain.AddBlock(append([]*blockchain.Transaction{cbTx}, txs...))
+	if err != nil {
+		return err
+	}
+	if err := pool.RemoveBlock(block); err != nil {
+		return err
+	}
+
+	fmt.Println("Success!!")
+
+	return nil
+}
+
+func (cli *CommandLine) mine(address string, max int, opts blockchain.MinerOptions) error {
+	if !wallet.ValidateAddress(address) {
+		return fmt.Errorf("%w %q", wallet.ErrInvalidAddress, address)
+	}
+
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+	chain.Miner = opts
+
+	pool, err := blockchain.NewMempool(chain)
+	if err != nil {
+		return err
+	}
+
+	txs := pool.Template(max)
+	cbTx, err := chain.NewCoinbase(address, txs)
+	if err != nil {
+		return err
+	}
+
+	block, err := chain.AddBlock(append([]*blockchain.Transaction{cbTx}, txs...))
+	if err != nil {
+		return err
+	}
+	if err := pool.RemoveBlock(block); err != nil {
+		return err
+	}
+
+	reward := 0
+	for _, out := range cbTx.Outputs { // no outputs when the supply is exhausted and the transactions pay no fees
+		reward += out.Value
+	}
+	fmt.Printf("Mined block %x with %d transactions, reward %d\n", block.Hash, len(txs), reward)
+
+	return nil
+}
+
+func (cli *CommandLine) printMempool() error {
+	if cli.client != nil {
+		var entries []rpc.MempoolEntry
+		if err := cli.client.Call("getmempool", nil, &entries); err != nil {
+			return err
+		}
+
+		fmt.Printf("Pending transactions: %d\n", len(entries))
+		for _, entry := range entries {
+			tx, err := entry.Transaction.Decode()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Fee: %d\n", entry.Fee)
+			fmt.Println(tx)
+		}
+
+		return nil
+	}
+
+	chain, err := blockchain.ContinueBlockChain(cli.options)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+
+	pool, err := blockchain.NewMempool(chain)
+	if err != nil {
+		return err
+	}
+
+	txs := pool.Transactions()
+	fmt.Printf("Pending transactions: %d\n", len(txs))
+	for _, tx := range txs {
+		fee, _ := pool.Fee(tx.ID)
+		fmt.Printf("Fee: %d\n", fee)
+		fmt.Println(tx)
+	}
+
+	return nil
+}
+
+func (cli *CommandLine) callRPC(method, params string) error {
+	var args interface{}
+	if params != "" {
+		if !json.Valid([]byte(params)) {
+			return fmt.Errorf("%w: the params are not valid JSON", errUsage)
+		}
+		args = json.RawMessage(params)
+	}
+
+	var result json.RawMessage
+	if err := cli.client.Call(method, args, &result); err != nil {
+		return err
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, result, "", "  "); err != nil {
+		return err
+	}
+	fmt.Println(indented.String())
+
+	return nil
+}
+
+func (cli *CommandLine) startNode(host, port, peers, minerAddress string, minTxs int, rpcHost, rpcPort, explorerPort string, opts blockchain.MinerOptions) error {
+	if minerAddress != "" && !wallet.ValidateAddress(minerAddress) {
+		return fmt.Errorf("%w %q", wallet.ErrInvalidAddress, minerAddress)
+	}
+
+	chain, err := blockchain.ContinueBlockChain(cli.options) // every node needs a copy of the same genesis block
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+	chain.Miner = opts
+
+	var peerList []string
+	for _, peer := range strings.Split(peers, ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			peerList = append(peerList, peer)
+		}
+	}
+
+	server, err := network.NewServer(net.JoinHostPort(host, port), chain, peerList)
+	if err != nil {
+		return err
+	}
+	server.MinerAddress = minerAddress
+	server.MinTxs = minTxs
+
+	if err := server.Start(); err != nil {
+		return err
+	}
+	fmt.Printf("Starting node %s\n", server.Address)
+	if minerAddress != "" {
+		fmt.Printf("Mining is on. Address to receive rewards: %s\n", minerAddress)
+	}
+
+	var rpcServer *rpc.Server
+	if rpcPort != "" {
+		rpcServer = rpc.NewServer(net.JoinHostPort(rpcHost, rpcPort), server, cli.options)
+		if err := rpcServer.Start(); err != nil {
+			server.Close()
+			return err
+		}
+		fmt.Printf("JSON-RPC server listening on %s\n", rpcServer.Address)
+	}
+
+	var explorerServer *explorer.Server
+	if explorerPort != "" {
+		if explorerServer, err = explorer.NewServer(net.JoinHostPort(host, explorerPort), server); err == nil {
+			err = explorerServer.Start()
+		}
+		if err != nil {
+			if rpcServer != nil {
+				rpcServer.Close()
+			}
+			server.Close()
+			return err
+		}
+		fmt.Printf("Block explorer listening on http://%s/\n", explorerServer.Address)
+	}
+
+	stop := make(chan os.Signal, 1) // run until the process is interrupted
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	fmt.Println("Stopping node")
+
+	if explorerServer != nil {
+		if err := explorerServer.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+	if rpcServer != nil {
+		if err := rpcServer.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+
+	return server.Close()
+}
+
+// Run : parse the command line arguments, execute the command and return the exit code of the process
+func (cli *CommandLine) Run() int {
+	err := cli.run()
+	if err != nil && !errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+
+	return exitCode(err)
+}
+
+// envOr : value of the environment variable, or the fallback when it's not set
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func (cli *CommandLine) run() error {
+	globalFlags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	globalFlags.Usage = cli.printUsage
+	dataDir := globalFlags.String("datadir", envOr(DataDirEnv, blockchain.DefaultDataDir), "Directory with the data of every network")
+	network := globalFlags.String("network", envOr(NetworkEnv, blockchain.DefaultNetwork), "Network of the chain")
+	rpcAddress := globalFlags.String("rpc", envOr(RPCEnv, ""), "HOST:PORT of a running daemon")
+	rpcCookie := globalFlags.String("rpccookie", envOr(RPCCookieEnv, ""), "Cookie file with the token of the daemon, the one of the network by default")
+	txIndexDefault, _ := strconv.ParseBool(envOr(TxIndexEnv, "false"))
+	txIndex := globalFlags.Bool("txindex", txIndexDefault, "Build the index of the transactions of the chain if it doesn't have one")
+	addrIndexDefault, _ := strconv.ParseBool(envOr(AddrIndexEnv, "false"))
+	addrIndex := globalFlags.Bool("addrindex", addrIndexDefault, "Build the index of the transactions of every address if the chain doesn't have one")
+	if err := globalFlags.Parse(os.Args[1:]); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	args := globalFlags.Args()
+
+	if err := cli.validateArgs(args); err != nil {
+		return err
+	}
+
+	cli.options = blockchain.Options{DataDir: *dataDir, Network: *network, TxIndex: *txIndex, AddressIndex: *addrIndex}
+	if _, err := cli.options.Params(); err != nil {
+		return err
+	}
+
+	if *rpcAddress != "" {
+		if !rpcCommands[args[0]] {
+			fmt.Printf("%s opens the database, it can't be sent to a daemon with -rpc\n", args[0])
+			return errUsage
+		}
+		cookie := *rpcCookie
+		if cookie == "" {
+			cookie = cli.options.CookieFile()
+		}
+		token, err := rpc.ReadCookie(cookie)
+		if err != nil {
+			return err
+		}
+		cli.client = rpc.NewClient(*rpcAddress, token)
+	}
+
+	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
+	historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
+	getBlockCmd := flag.NewFlagSet("getblock", flag.ExitOnError)
+	getBlockCountCmd := flag.NewFlagSet("getblockcount", flag.ExitOnError)
+	getTxCmd := flag.NewFlagSet("gettx", flag.ExitOnError)
+	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
+	proveTxCmd := flag.NewFlagSet("provetx", flag.ExitOnError)
+	verifyProofCmd := flag.NewFlagSet("verifyproof", flag.ExitOnError)
+	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	mineCmd := flag.NewFlagSet("mine", flag.ExitOnError)
+	mempoolCmd := flag.NewFlagSet("mempool", flag.ExitOnError)
+	supplyCmd := flag.NewFlagSet("supply", flag.ExitOnError)
+	verifyChainCmd := flag.NewFlagSet("verifychain", flag.ExitOnError)
+	migrateCmd := flag.NewFlagSet("migrate", flag.ExitOnError)
+	exportChainCmd := flag.NewFlagSet("exportchain", flag.ExitOnError)
+	importChainCmd := flag.NewFlagSet("importchain", flag.ExitOnError)
+	rpcCmd := flag.NewFlagSet("rpc", flag.ExitOnError)
+
+	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+	historyAddress := historyCmd.String("address", "", "The address to get the history for")
+	historyLimit := historyCmd.Int("limit", 0, "Maximum number of transactions to print, all of them by default")
+	historyOffset := historyCmd.Int("offset", 0, "Number of newest transactions to skip")
+	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
+	printChainFrom := printChainCmd.Int("from", 0, "Height of the oldest block to print")
+	printChainTo := printChainCmd.Int("to", -1, "Height of the newest block to print, the tip by default")
+	getBlockHash := getBlockCmd.String("hash", "", "Hash of the block")
+	getBlockHeight := getBlockCmd.Int("height", -1, "Height of the block in the main chain")
+	getTxID := getTxCmd.String("id", "", "ID of the transaction")
+	sendFrom := sendCmd.String("from", "", "Source wallet address")
+	sendTo := sendCmd.String("to", "", "Destination wallet address")
+	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendFee := sendCmd.Int("fee", 0, "Fee paid to the miner of the transaction")
+	sendThreads := sendCmd.Int("threads", 0, "Number of mining threads, one per CPU by default")
+	sendQuiet := sendCmd.Bool("quiet", false, "Don't print the mining progress")
+	sendNode := sendCmd.String("node", "", "Send the transaction to the node at HOST:PORT instead of mining it")
+	sendPending := sendCmd.Bool("pending", false, "Keep the transaction in the mempool instead of mining it")
+	mineAddress := mineCmd.String("address", "", "The address to send the block reward to")
+	mineMax := mineCmd.Int("max", 0, "Maximum number of transactions of the mempool in the block, all of them by default")
+	mineThreads := mineCmd.Int("threads", 0, "Number of mining threads, one per CPU by default")
+	mineQuiet := mineCmd.Bool("quiet", false, "Don't print the mining progress")
+	verifyChainLast := verifyChainCmd.Int("last", 0, "Verify only the last N blocks, the whole chain by default")
+	migrateDryRun := migrateCmd.Bool("dryrun", false, "Report what would change without changing the database")
+	exportChainOut := exportChainCmd.String("out", "", "Bootstrap file to write")
+	importChainIn := importChainCmd.String("in", "", "Bootstrap file to read")
+	proveTxID := proveTxCmd.String("txid", "", "ID of the transaction to prove")
+	proveTxBlock := proveTxCmd.String("block", "", "Hash of the block that contains the transaction")
+	verifyProofFile := verifyProofCmd.String("proof", "", "File with a proof printed by provetx")
+	startNodePort := startNodeCmd.String("port", "", "Port where the node listens")
+	startNodeHost := startNodeCmd.String("host", "localhost", "Host where the node listens, the peers connect to HOST:PORT")
+	startNodePeers := startNodeCmd.String("peers", "", "Comma separated HOST:PORT addresses of the nodes to connect to")
+	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining and send the rewards to the address")
+	startNodeMinTxs := startNodeCmd.Int("mintxs", 1, "Transactions needed in the pool before a block is mined")
+	startNodeThreads := startNodeCmd.Int("threads", 0, "Number of mining threads, one per CPU by default")
+	startNodeRPCPort := startNodeCmd.String("rpcport", "", "Port where the JSON-RPC server listens, disabled by default")
+	startNodeRPCHost := startNodeCmd.String("rpchost", "127.0.0.1", "Host where the JSON-RPC server listens, only this machine can connect by default")
+	startNodeExplorerPort := startNodeCmd.String("explorerport", "", "Port where the block explorer listens, disabled by default")
+	rpcMethod := rpcCmd.String("method", "", "Method to call")
+	rpcParams := rpcCmd.String("params", "", "Params of the method as a JSON object")
+
+	var err error
+	switch args[0] {
+	case "getbalance":
+		err = getBalanceCmd.Parse(args[1:])
+	case "history":
+		err = historyCmd.Parse(args[1:])
+	case "createblockchain":
+		err = createBlockchainCmd.Parse(args[1:])
+	case "printchain":
+		err = printChainCmd.Parse(args[1:])
+	case "getblock":
+		err = getBlockCmd.Parse(args[1:])
+	case "getblockcount":
+		err = getBlockCountCmd.Parse(args[1:])
+	case "gettx":
+		err = getTxCmd.Parse(args[1:])
+	case "send":
+		err = sendCmd.Parse(args[1:])
+	case "createwallet":
+		err = createWalletCmd.Parse(args[1:])
+	case "listaddresses":
+		err = listAddressesCmd.Parse(args[1:])
+	case "reindexutxo":
+		err = reindexUTXOCmd.Parse(args[1:])
+	case "provetx":
+		err = proveTxCmd.Parse(args[1:])
+	case "verifyproof":
+		err = verifyProofCmd.Parse(args[1:])
+	case "startnode":
+		err = startNodeCmd.Parse(args[1:])
+	case "mine":
+		err = mineCmd.Parse(args[1:])
+	case "mempool":
+		err = mempoolCmd.Parse(args[1:])
+	case "supply":
+		err = supplyCmd.Parse(args[1:])
+	case "verifychain":
+		err = verifyChainCmd.Parse(args[1:])
+	case "migrate":
+		err = migrateCmd.Parse(args[1:])
+	case "exportchain":
+		err = exportChainCmd.Parse(args[1:])
+	case "importchain":
+		err = importChainCmd.Parse(args[1:])
+	case "rpc":
+		err = rpcCmd.Parse(args[1:])
+	default:
+		cli.printUsage()
+		return errUsage
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	if getBalanceCmd.Parsed() {
+		if *getBalanceAddress == "" {
+			getBalanceCmd.Usage()
+			return errUsage
+		}
+		return cli.getBalance(*getBalanceAddress)
+	}
+
+	if historyCmd.Parsed() {
+		if *historyAddress == "" || *historyLimit < 0 || *historyOffset < 0 {
+			historyCmd.Usage()
+			return errUsage
+		}
+		return cli.history(*historyAddress, *historyLimit, *historyOffset)
+	}
+
+	if createBlockchainCmd.Parsed() {
+		if *createBlockchainAddress == "" {
+			createBlockchainCmd.Usage()
+			return errUsage
+		}
+		return cli.createBlockChain(*createBlockchainAddress)
+	}
+
+	if printChainCmd.Parsed() {
+		if *printChainFrom < 0 || (*printChainTo >= 0 && *printChainTo < *printChainFrom) {
+			printChainCmd.Usage()
+			return errUsage
+		}
+		return cli.printChain(*printChainFrom, *printChainTo)
+	}
+
+	if getBlockCmd.Parsed() {
+		if (*getBlockHash == "") == (*getBlockHeight < 0) { // exactly one of them
+			getBlockCmd.Usage()
+			return errUsage
+		}
+		return cli.getBlock(*getBlockHash, *getBlockHeight)
+	}
+
+	if getBlockCountCmd.Parsed() {
+		return cli.getBlockCount()
+	}
+
+	if getTxCmd.Parsed() {
+		if *getTxID == "" {
+			getTxCmd.Usage()
+			return errUsage
+		}
+		return cli.getTx(*getTxID)
+	}
+
+	if createWalletCmd.Parsed() {
+		return cli.createWallet()
+	}
+
+	if listAddressesCmd.Parsed() {
+		return cli.listAddresses()
+	}
+
+	if reindexUTXOCmd.Parsed() {
+		return cli.reindexUTXO()
+	}
+
+	if proveTxCmd.Parsed() {
+		if *proveTxID == "" || *proveTxBlock == "" {
+			proveTxCmd.Usage()
+			return errUsage
+		}
+		return cli.proveTx(*proveTxID, *proveTxBlock)
+	}
+
+	if verifyProofCmd.Parsed() {
+		if *verifyProofFile == "" {
+			verifyProofCmd.Usage()
+			return errUsage
+		}
+		return cli.verifyProof(*verifyProofFile)
+	}
+
+	if mineCmd.Parsed() {
+		if *mineAddress == "" || *mineMax < 0 {
+			mineCmd.Usage()
+			return errUsage
+		}
+		return cli.mine(*mineAddress, *mineMax, miner(*mineThreads, *mineQuiet))
+	}
+
+	if mempoolCmd.Parsed() {
+		return cli.printMempool()
+	}
+
+	if supplyCmd.Parsed() {
+		return cli.supply()
+	}
+
+	if rpcCmd.Parsed() {
+		if *rpcMethod == "" || cli.client == nil {
+			rpcCmd.Usage()
+			return errUsage
+		}
+		return cli.callRPC(*rpcMethod, *rpcParams)
+	}
+
+	if verifyChainCmd.Parsed() {
+		if *verifyChainLast < 0 {
+			verifyChainCmd.Usage()
+			return errUsage
+		}
+		return cli.verifyChain(*verifyChainLast)
+	}
+
+	if migrateCmd.Parsed() {
+		return cli.migrate(*migrateDryRun)
+	}
+
+	if exportChainCmd.Parsed() {
+		if *exportChainOut == "" {
+			exportChainCmd.Usage()
+			return errUsage
+		}
+		return cli.exportChain(*exportChainOut)
+	}
+
+	if importChainCmd.Parsed() {
+		if *importChainIn == "" {
+			importChainCmd.Usage()
+			return errUsage
+		}
+		return cli.importChain(*importChainIn)
+	}
+
+	if startNodeCmd.Parsed() {
+		if *startNodePort == "" || *startNodeMinTxs < 1 {
+			startNodeCmd.Usage()
+			return errUsage
+		}
+		return cli.startNode(*startNodeHost, *startNodePort, *startNodePeers, *startNodeMiner, *startNodeMinTxs, *startNodeRPCHost, *startNodeRPCPort, *startNodeExplorerPort, miner(*startNodeThreads, true))
+	}
+
+	if sendCmd.Parsed() {
+		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 || *sendFee < 0 || (*sendPending && *sendNode != "") || (cli.client != nil && *sendNode != "") {
+			sendCmd.Usage()
+			return errUsage
+		}
+
+		return cli.send(*sendFrom, *sendTo, *sendAmount, *sendFee, *sendPending, *sendNode, miner(*sendThreads, *sendQuiet))
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Dieg0Code/Blockchain.go/blockchain"
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+	"golang.org/x/net/websocket"
+)
+
+// topics : the event types that a client can subscribe to
+var topics = map[string]blockchain.EventType{
+	string(blockchain.EventBlockConnected):    blockchain.EventBlockConnected,
+	string(blockchain.EventBlockDisconnected): blockchain.EventBlockDisconnected,
+	string(blockchain.EventTxConfirmed):       blockchain.EventTxConfirmed,
+	string(blockchain.EventTxAccepted):        blockchain.EventTxAccepted,
+	string(blockchain.EventAddressReceived):   blockchain.EventAddressReceived,
+}
+
+// EventMessage : an event sent to the clients of the WebSocket
+type EventMessage struct {
+	Topic     string   `json:"topic"`
+	BlockHash string   `json:"blockHash,omitempty"`
+	Height    int      `json:"height"` // -1 for a transaction of the mempool
+	TxIDs     []string `json:"txids"`
+	Addresses []string `json:"addresses"`
+	Value     int      `json:"value,omitempty"` // coins received by the address of an addressreceived event
+}
+
+// Subscribe : the topics and addresses of the events that a client wants, an empty list matches everything,
+// a client sends it to replace the filter that it set when it connected
+type Subscribe struct {
+	Topics    []string `json:"topics"`
+	Addresses []string `json:"addresses"`
+}
+
+// Subscribed : the answer to a Subscribe message
+type Subscribed struct {
+	Subscribed Subscribe `json:"subscribed"`
+}
+
+// newEventMessage : JSON view of the event
+func newEventMessage(event blockchain.Event) EventMessage {
+	message := EventMessage{
+		Topic:     string(event.Type),
+		BlockHash: hex.EncodeToString(event.BlockHash),
+		Height:    event.Height,
+		TxIDs:     make([]string, 0, len(event.TxIDs)),
+		Addresses: append([]string{}, event.Addresses...),
+		Value:     event.Value,
+	}
+	for _, ID := range event.TxIDs {
+		message.TxIDs = append(message.TxIDs, hex.EncodeToString(ID))
+	}
+
+	return message
+}
+
+// newFilter : filter of the bus with the topics and addresses, unknown topics and invalid addresses are rejected
+func newFilter(subscribe Subscribe) (blockchain.EventFilter, error) {
+	var filter blockchain.EventFilter
+
+	for _, topic := range subscribe.Topics {
+		eventType, ok := topics[topic]
+		if !ok {
+			return filter, fmt.Errorf("unknown topic %q", topic)
+		}
+		filter.Types = append(filter.Types, eventType)
+	}
+	for _, address := range subscribe.Addresses {
+		if !wallet.ValidateAddress(address) {
+			return filter, fmt.Errorf("%w %q", wallet.ErrInvalidAddress, address)
+		}
+		filter.Addresses = append(filter.Addresses, address)
+	}
+
+	return filter, nil
+}
+
+// listParam : the comma separated values of the query param with the name
+func listParam(r *http.Request, name string) []string {
+	var values []string
+	for _, value := range strings.Split(r.URL.Query().Get(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// handleEvents : upgrade the request to a WebSocket that receives the events that match the topics and addresses params
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	subscribe := Subscribe{Topics: listParam(r, "topics"), Addresses: listParam(r, "addresses")}
+	filter, err := newFilter(subscribe)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !s.track() {
+		writeError(w, http.StatusServiceUnavailable, errors.New("the explorer is stopping"))
+		return
+	}
+	defer s.conns.Done()
+
+	server := websocket.Server{
+		Handshake: func(*websocket.Config, *http.Request) error { return nil }, // any origin, the events are public
+		Handler: func(ws *websocket.Conn) {
+			s.streamEvents(ws, filter)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// track : count a WebSocket connection so Close waits for it, false when the explorer is stopping
+func (s *Server) track() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+	s.conns.Add(1)
+
+	return true
+}
+
+// streamEvents : send the events to the client until it disconnects or the explorer stops
+func (s *Server) streamEvents(ws *websocket.Conn, filter blockchain.EventFilter) {
+	defer ws.Close()
+	ws.SetDeadline(time.Time{}) // the timeouts of the HTTP server are kept by the hijacked connection
+
+	var sub *blockchain.Subscription
+	s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		sub = chain.Events.Subscribe(filter)
+		return nil
+	})
+	defer sub.Close()
+
+	replies := make(chan interface{})
+	done := make(chan struct{})
+	go func() { // the client can replace its filter at any time
+		defer close(done)
+		for {
+			var subscribe Subscribe
+			err := websocket.JSON.Receive(ws, &subscribe)
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				err = fmt.Errorf("invalid message: %v", err)
+			} else if err != nil { // disconnected
+				return
+			} else if filter, ferr := newFilter(subscribe); ferr != nil {
+				err = ferr
+			} else {
+				sub.SetFilter(filter)
+			}
+
+			var reply interface{} = Subscribed{subscribe}
+			if err != nil {
+				reply = map[string]string{"error": err.Error()}
+			}
+			select {
+			case replies <- reply:
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+
+	send := func(v interface{}) bool {
+		ws.SetWriteDeadline(time.Now().Add(ioTimeout))
+		if err := websocket.JSON.Send(ws, v); err != nil {
+			s.Logger.Printf("Events %s: %v", ws.Request().RemoteAddr, err)
+			return false
+		}
+		return true
+	}
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				if sub.Dropped() {
+					send(map[string]string{"error": "the events were not read fast enough"})
+				}
+				return
+			}
+			if !send(newEventMessage(event)) {
+				return
+			}
+		case reply := <-replies:
+			if !send(reply) {
+				return
+			}
+		case <-done:
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
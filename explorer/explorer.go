@@ -0,0 +1,504 @@
+package explorer
+
+import (
+	"context"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dieg0Code/Blockchain.go/blockchain"
+	"github.com/Dieg0Code/Blockchain.go/network"
+	"github.com/Dieg0Code/Blockchain.go/rpc"
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+/*
+	The explorer is a read only HTTP API over the chain of a running node, and a small web page
+	embedded in the binary that uses it. Every response of the API is JSON, the hashes are hex
+	encoded and the blocks and transactions have the same shape as in the JSON-RPC server. The
+	list of blocks is paged walking the chain backwards from a block, each page tells the hash of
+	the block where the next page starts.
+
+	GET /blocks?start=HASH&limit=N   blocks from the tip, or from the block with the hash
+	GET /blocks/HASH                 a block with its transactions
+	GET /tx/ID                       a transaction and the block that contains it
+	GET /address/ADDRESS             balance, unspent outputs and history of an address
+	GET /search?q=QUERY              what a height, hash, ID or address is
+	GET /ws?topics=T,...&addresses=A,...
+	                                 WebSocket with the events of the chain that match the filter
+
+	The clients of the WebSocket get a JSON message for every event of the chain that has one of the
+	topics and involves one of the addresses, leaving a list empty matches everything. The topics
+	are blockconnected, blockdisconnected, txconfirmed, txaccepted and addressreceived. Sending
+	{"topics": [...], "addresses": [...]} replaces the filter.
+
+	Esp:
+
+	El explorador es una API HTTP de solo lectura sobre la cadena de un nodo en ejecución, y una
+	pequeña pagina web incluida en el binario que la usa. Cada respuesta de la API es JSON, los
+	hashes van en hexadecimal y los bloques y transacciones tienen la misma forma que en el servidor
+	JSON-RPC. La lista de bloques se pagina recorriendo la cadena hacia atrás desde un bloque, cada
+	pagina indica el hash del bloque donde empieza la siguiente pagina.
+
+	Los clientes del WebSocket reciben un mensaje JSON por cada evento de la cadena que tenga uno de
+	los topics e involucre una de las direcciones, dejar una lista vacía coincide con todo. Los
+	topics son blockconnected, blockdisconnected, txconfirmed, txaccepted y addressreceived. Enviar
+	{"topics": [...], "addresses": [...]} reemplaza el filtro.
+*/
+
+//go:embed ui
+var ui embed.FS
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	ioTimeout       = 30 * time.Second // time to read a request or write a response
+)
+
+var hashPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// BlockSummary : a block of the list of blocks
+type BlockSummary struct {
+	Hash         string `json:"hash"`
+	Height       int    `json:"height"`
+	Timestamp    int64  `json:"timestamp"`
+	Difficulty   int    `json:"difficulty"`
+	Transactions int    `json:"transactions"`
+}
+
+// BlockPage : a page of the list of blocks, the next page starts at the block with the hash Next
+type BlockPage struct {
+	Blocks []BlockSummary `json:"blocks"`
+	Next   string         `json:"next,omitempty"`
+}
+
+// UTXO : an unspent output of an address
+type UTXO struct {
+	TxID  string `json:"txid"`
+	Out   int    `json:"out"`
+	Value int    `json:"value"`
+}
+
+// HistoryEntry : a transaction that sends coins to or from an address
+type HistoryEntry struct {
+	TxID      string `json:"txid"`
+	BlockHash string `json:"blockHash"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Received  int    `json:"received"`
+	Sent      int    `json:"sent"`
+}
+
+// AddressInfo : the coins of an address
+type AddressInfo struct {
+	Address string         `json:"address"`
+	Balance int            `json:"balance"`
+	UTXOs   []UTXO         `json:"utxos"`
+	History []HistoryEntry `json:"history"`
+}
+
+// SearchResult : what the query of a search is, the type is block, tx or address
+type SearchResult struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Server : block explorer of a node
+type Server struct {
+	Address string // host:port where the server listens
+	Logger  *log.Logger
+
+	node *network.Server
+	mux  *http.ServeMux
+	http *http.Server
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	closing bool
+	quit    chan struct{}  // closed by Close to end the WebSocket connections
+	conns   sync.WaitGroup // WebSocket connections, the HTTP server forgets them after the upgrade
+}
+
+// NewServer : explorer that listens on the address and reads the chain of the node
+func NewServer(address string, node *network.Server) (*Server, error) {
+	static, err := fs.Sub(ui, "ui")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Address: address,
+		Logger:  log.New(os.Stderr, fmt.Sprintf("[explorer %s] ", address), log.LstdFlags),
+		node:    node,
+		mux:     http.NewServeMux(),
+		quit:    make(chan struct{}),
+	}
+
+	files := http.FileServer(http.FS(static))
+	s.mux.Handle("/static/", http.StripPrefix("/static/", files))
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			writeError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+			return
+		}
+		files.ServeHTTP(w, r) // index.html
+	})
+	s.mux.HandleFunc("/blocks", s.handleBlocks)
+	s.mux.HandleFunc("/blocks/", s.handleBlock)
+	s.mux.HandleFunc("/tx/", s.handleTx)
+	s.mux.HandleFunc("/address/", s.handleAddress)
+	s.mux.HandleFunc("/search", s.handleSearch)
+	s.mux.HandleFunc("/ws", s.handleEvents)
+
+	return s, nil
+}
+
+// Start : listen for requests in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	s.Address = ln.Addr().String() // the real port when a random one was requested
+	s.Logger.SetPrefix(fmt.Sprintf("[explorer %s] ", s.Address))
+
+	s.http = &http.Server{
+		Handler:      s,
+		ReadTimeout:  ioTimeout,
+		WriteTimeout: ioTimeout,
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.http.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.Logger.Printf("Serve: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Close : stop listening, close the WebSocket connections and wait until the requests being handled are done
+func (s *Server) Close() error {
+	if s.http == nil { // never started
+		return nil
+	}
+
+	s.mu.Lock()
+	if !s.closing {
+		s.closing = true
+		close(s.quit)
+	}
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ioTimeout)
+	defer cancel()
+
+	err := s.http.Shutdown(ctx)
+	s.wg.Wait()
+	s.conns.Wait()
+
+	return err
+}
+
+// ServeHTTP : only GET requests are answered
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("the explorer is read only"))
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	limit, err := intParam(r, "limit", defaultPageSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if limit <= 0 || limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	var start []byte
+	if param := r.URL.Query().Get("start"); param != "" {
+		if start, err = decodeHash(param); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	page := BlockPage{Blocks: []BlockSummary{}}
+	err = s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		iter := chain.Iterator()
+		if start != nil {
+			iter.CurrentHash = start
+		}
+
+		for len(page.Blocks) < limit {
+			block, err := iter.Next()
+			if err != nil {
+				return err
+			}
+			page.Blocks = append(page.Blocks, BlockSummary{
+				Hash:         hex.EncodeToString(block.Hash),
+				Height:       block.Height,
+				Timestamp:    block.Timestamp,
+				Difficulty:   block.Difficulty,
+				Transactions: len(block.Transactions),
+			})
+			if len(block.PrevHash) == 0 { // the genesis block is the last page
+				return nil
+			}
+		}
+		page.Next = hex.EncodeToString(iter.CurrentHash)
+
+		return nil
+	})
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, page)
+}
+
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	hash, err := decodeHash(strings.TrimPrefix(r.URL.Path, "/blocks/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var block blockchain.Block
+	err = s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		block, err = chain.GetBlock(hash)
+		return err
+	})
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, rpc.NewBlock(&block))
+}
+
+func (s *Server) handleTx(w http.ResponseWriter, r *http.Request) {
+	ID, err := decodeHash(strings.TrimPrefix(r.URL.Path, "/tx/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var info rpc.TransactionInfo
+	err = s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		if tx, ok := pool.Get(ID); ok { // not mined yet
+			info = rpc.TransactionInfo{Transaction: rpc.NewTransaction(tx), Height: -1}
+			return nil
+		}
+
+		best, err := chain.GetBestHeight()
+		if err != nil {
+			return err
+		}
+		tx, block, err := chain.FindTransactionBlock(ID)
+		if err != nil {
+			return err
+		}
+		info = rpc.TransactionInfo{
+			Transaction:   rpc.NewTransaction(tx),
+			BlockHash:     hex.EncodeToString(block.Hash),
+			Height:        block.Height,
+			Confirmations: best - block.Height + 1,
+		}
+
+		return nil
+	})
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, info)
+}
+
+func (s *Server) handleAddress(w http.ResponseWriter, r *http.Request) {
+	address := strings.TrimPrefix(r.URL.Path, "/address/")
+	pubKeyHash, err := wallet.PubKeyHashFromAddress(address)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := intParam(r, "limit", 50)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	offset, err := intParam(r, "offset", 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid offset %q", r.URL.Query().Get("offset")))
+		return
+	}
+
+	info := AddressInfo{Address: address, UTXOs: []UTXO{}, History: []HistoryEntry{}}
+	err = s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		unspent, err := UTXOSet.FindUnspent(pubKeyHash)
+		if err != nil {
+			return err
+		}
+		for _, u := range unspent {
+			info.Balance += u.Output.Value
+			info.UTXOs = append(info.UTXOs, UTXO{hex.EncodeToString(u.ID), u.Out, u.Output.Value})
+		}
+
+		history, err := chain.AddressHistory(pubKeyHash, offset, limit)
+		if err != nil {
+			return err
+		}
+		for _, entry := range history {
+			info.History = append(info.History, HistoryEntry{
+				TxID:      hex.EncodeToString(entry.TxID),
+				BlockHash: hex.EncodeToString(entry.BlockHash),
+				Height:    entry.Height,
+				Timestamp: entry.Timestamp,
+				Received:  entry.Received,
+				Sent:      entry.Sent,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, info)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, errors.New("missing q"))
+		return
+	}
+
+	if wallet.ValidateAddress(query) {
+		writeJSON(w, SearchResult{"address", query})
+		return
+	}
+
+	var result *SearchResult
+	err := s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		if height, err := strconv.Atoi(query); err == nil { // a height of the main chain
+			hash, err := chain.BlockHashAt(height)
+			if errors.Is(err, blockchain.ErrBlockNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			result = &SearchResult{"block", hex.EncodeToString(hash)}
+			return nil
+		}
+
+		if !hashPattern.MatchString(query) {
+			return nil
+		}
+		hash, _ := hex.DecodeString(query)
+
+		found, err := chain.HasBlock(hash)
+		if err != nil {
+			return err
+		}
+		if found {
+			result = &SearchResult{"block", hex.EncodeToString(hash)}
+			return nil
+		}
+
+		if _, ok := pool.Get(hash); ok {
+			result = &SearchResult{"tx", hex.EncodeToString(hash)}
+			return nil
+		}
+		_, _, err = chain.FindTransactionBlock(hash)
+		if errors.Is(err, blockchain.ErrTxNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result = &SearchResult{"tx", hex.EncodeToString(hash)}
+
+		return nil
+	})
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if result == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("nothing matches %q", query))
+		return
+	}
+
+	writeJSON(w, result)
+}
+
+// decodeHash : the hex encoded hash of a block or a transaction
+func decodeHash(value string) ([]byte, error) {
+	if !hashPattern.MatchString(value) {
+		return nil, fmt.Errorf("%q is not a hash", value)
+	}
+
+	return hex.DecodeString(value)
+}
+
+// intParam : the integer query param with the name, or the fallback when it's not set
+func intParam(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+
+	return n, nil
+}
+
+// errorStatus : the HTTP status of an error of the chain
+func errorStatus(err error) int {
+	if errors.Is(err, blockchain.ErrBlockNotFound) || errors.Is(err, blockchain.ErrTxNotFound) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,171 @@
+// Explorer page, every view is a route after the # of the URL and reads the JSON API of the node.
+(function () {
+  "use strict";
+
+  var view = document.getElementById("view");
+  var historyPageSize = 50;
+
+  function esc(value) {
+    return String(value).replace(/[&<>"']/g, function (c) {
+      return { "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;" }[c];
+    });
+  }
+
+  function get(path) {
+    return fetch(path).then(function (resp) {
+      return resp.json().then(function (body) {
+        if (!resp.ok) {
+          throw new Error(body.error || resp.statusText);
+        }
+        return body;
+      });
+    });
+  }
+
+  function time(timestamp) {
+    return new Date(timestamp * 1000).toLocaleString();
+  }
+
+  function link(route, text) {
+    return '<a class="hash" href="#/' + route + '">' + esc(text) + "</a>";
+  }
+
+  function blockLink(hash) { return link("block/" + hash, hash); }
+  function txLink(id) { return link("tx/" + id, id); }
+  function addressLink(address) { return link("address/" + address, address); }
+
+  function fields(rows) {
+    return '<table class="fields">' + rows.map(function (row) {
+      return "<tr><th>" + esc(row[0]) + "</th><td>" + row[1] + "</td></tr>";
+    }).join("") + "</table>";
+  }
+
+  function transaction(tx) {
+    var inputs = tx.coinbase
+      ? "<p>Coinbase, new coins</p>"
+      : "<table>" + tx.inputs.map(function (input) {
+        return "<tr><td>" + txLink(input.txid) + " #" + esc(input.out) + "</td></tr>";
+      }).join("") + "</table>";
+    var outputs = "<table>" + tx.outputs.map(function (output, i) {
+      return "<tr><td>#" + i + "</td><td>" + addressLink(output.address) + "</td><td>" + esc(output.value) + "</td></tr>";
+    }).join("") + "</table>";
+
+    return '<div class="tx"><div>' + txLink(tx.txid) + "</div>" +
+      '<div class="io"><div><h3>Inputs</h3>' + inputs + "</div>" +
+      "<div><h3>Outputs</h3>" + outputs + "</div></div></div>";
+  }
+
+  function showBlocks(params) {
+    var path = "/blocks" + (params.get("start") ? "?start=" + encodeURIComponent(params.get("start")) : "");
+    return get(path).then(function (page) {
+      var rows = page.blocks.map(function (block) {
+        return "<tr><td>" + esc(block.height) + "</td><td>" + blockLink(block.hash) + "</td><td>" +
+          esc(time(block.timestamp)) + "</td><td>" + esc(block.transactions) + "</td><td>" + esc(block.difficulty) + "</td></tr>";
+      }).join("");
+      var pager = '<div class="pager"><a href="#/">Latest</a>' +
+        (page.next ? '<a href="#/blocks?start=' + esc(page.next) + '">Older</a>' : "") + "</div>";
+
+      view.innerHTML = "<h2>Blocks</h2><table><tr><th>Height</th><th>Hash</th><th>Time</th><th>Txs</th><th>Difficulty</th></tr>" +
+        rows + "</table>" + pager;
+    });
+  }
+
+  function showBlock(hash) {
+    return get("/blocks/" + encodeURIComponent(hash)).then(function (block) {
+      var prev = block.prevHash ? blockLink(block.prevHash) : "none, genesis block";
+      view.innerHTML = "<h2>Block " + esc(block.height) + "</h2>" + fields([
+        ["Hash", '<span class="hash">' + esc(block.hash) + "</span>"],
+        ["Previous block", prev],
+        ["Merkle root", '<span class="hash">' + esc(block.merkleRoot) + "</span>"],
+        ["Time", esc(time(block.timestamp))],
+        ["Difficulty", esc(block.difficulty)],
+        ["Nonce", esc(block.nonce)],
+        ["Version", esc(block.version)]
+      ]) + "<h2>Transactions (" + block.transactions.length + ")</h2>" + block.transactions.map(transaction).join("");
+    });
+  }
+
+  function showTx(id) {
+    return get("/tx/" + encodeURIComponent(id)).then(function (info) {
+      var where = info.height < 0
+        ? [["Status", "Unconfirmed, in the mempool"]]
+        : [["Block", blockLink(info.blockHash)], ["Height", esc(info.height)], ["Confirmations", esc(info.confirmations)]];
+      view.innerHTML = "<h2>Transaction</h2>" + fields(where) + transaction(info);
+    });
+  }
+
+  function showAddress(address, params) {
+    var offset = parseInt(params.get("offset"), 10) || 0;
+    var path = "/address/" + encodeURIComponent(address) + "?limit=" + historyPageSize + "&offset=" + offset;
+    return get(path).then(function (info) {
+      var utxos = info.utxos.map(function (utxo) {
+        return "<tr><td>" + txLink(utxo.txid) + " #" + esc(utxo.out) + "</td><td>" + esc(utxo.value) + "</td></tr>";
+      }).join("");
+      var history = info.history.map(function (entry) {
+        return "<tr><td>" + esc(entry.height) + "</td><td>" + txLink(entry.txid) + "</td><td>" + esc(time(entry.timestamp)) +
+          '</td><td class="received">' + (entry.received ? "+" + esc(entry.received) : "") +
+          '</td><td class="sent">' + (entry.sent ? "-" + esc(entry.sent) : "") + "</td></tr>";
+      }).join("");
+      var route = "#/address/" + esc(address) + "?offset=";
+      var pager = '<div class="pager">' +
+        (offset > 0 ? '<a href="' + route + Math.max(0, offset - historyPageSize) + '">Newer</a>' : "") +
+        (info.history.length === historyPageSize ? '<a href="' + route + (offset + historyPageSize) + '">Older</a>' : "") + "</div>";
+
+      view.innerHTML = "<h2>Address</h2>" + fields([
+        ["Address", '<span class="hash">' + esc(info.address) + "</span>"],
+        ["Balance", esc(info.balance)]
+      ]) + "<h2>Unspent outputs</h2><table><tr><th>Output</th><th>Value</th></tr>" + utxos + "</table>" +
+        "<h2>History</h2><table><tr><th>Height</th><th>Transaction</th><th>Time</th><th>Received</th><th>Sent</th></tr>" +
+        history + "</table>" + pager;
+    });
+  }
+
+  function route() {
+    var hash = location.hash.replace(/^#\/?/, "");
+    var query = hash.indexOf("?");
+    var params = new URLSearchParams(query < 0 ? "" : hash.slice(query + 1));
+    var parts = (query < 0 ? hash : hash.slice(0, query)).split("/");
+
+    var shown;
+    switch (parts[0]) {
+      case "block": shown = showBlock(parts[1]); break;
+      case "tx": shown = showTx(parts[1]); break;
+      case "address": shown = showAddress(parts[1], params); break;
+      default: shown = showBlocks(params);
+    }
+    shown.catch(function (err) {
+      view.innerHTML = '<p class="error">' + esc(err.message) + "</p>";
+    });
+  }
+
+  document.getElementById("search").addEventListener("submit", function (event) {
+    event.preventDefault();
+    var q = event.target.q.value.trim();
+    if (!q) {
+      return;
+    }
+    get("/search?q=" + encodeURIComponent(q)).then(function (result) {
+      location.hash = "#/" + result.type + "/" + result.id;
+    }).catch(function (err) {
+      view.innerHTML = '<p class="error">' + esc(err.message) + "</p>";
+    });
+  });
+
+  // The list of the latest blocks is shown again when the tip of the chain moves
+  function listen() {
+    var scheme = location.protocol === "https:" ? "wss://" : "ws://";
+    var ws = new WebSocket(scheme + location.host + "/ws?topics=blockconnected,blockdisconnected");
+    ws.onmessage = function () {
+      if (location.hash.replace(/^#\/?/, "") === "") {
+        route();
+      }
+    };
+    ws.onclose = function () {
+      setTimeout(listen, 5000);
+    };
+  }
+
+  window.addEventListener("hashchange", route);
+  route();
+  listen();
+})();
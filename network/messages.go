@@ -0,0 +1,120 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+/*
+	Every message is sent in its own TCP connection, the first commandLength bytes are the name of
+	the command padded with zeros and the rest is the payload encoded with gob. The blocks and
+	transactions inside the payloads are encoded with the binary format of the blockchain package,
+	so every node gets the same bytes for them. The node that sends the message includes its own
+	address so the receiver can answer to it.
+
+	Esp:
+
+	Cada mensaje se envía en su propia conexión TCP, los primeros commandLength bytes son el nombre
+	del comando rellenado con ceros y el resto es el payload codificado con gob. Los bloques y
+	transacciones dentro de los payloads se codifican con el formato binario del paquete
+	blockchain, asi cada nodo obtiene los mismos bytes para ellos. El nodo que envía el mensaje
+	incluye su propia dirección para que el que lo recibe le pueda responder.
+*/
+
+const commandLength = 12
+
+// Kinds of items announced with inv and requested with getdata
+const (
+	blockType = "block"
+	txType    = "tx"
+)
+
+// Addr : addresses of the nodes known by the sender
+type Addr struct {
+	AddrList []string
+}
+
+// Block : a serialized block
+type Block struct {
+	AddrFrom string
+	Block    []byte
+}
+
+// GetBlocks : asks for the hashes of the blocks that follow the last block of the sender
+type GetBlocks struct {
+	AddrFrom string
+	Locator  []byte // hash of the last block of the sender
+}
+
+// GetData : asks for a block or a transaction
+type GetData struct {
+	AddrFrom string
+	Type     string
+	ID       []byte
+}
+
+// Inv : announces the blocks or transactions that the sender has
+type Inv struct {
+	AddrFrom string
+	Type     string
+	Items    [][]byte
+}
+
+// Tx : a serialized transaction
+type Tx struct {
+	AddrFrom    string
+	Transaction []byte
+}
+
+// Version : first message between two nodes, tells how much work the chain of the sender has
+type Version struct {
+	Version    int
+	Genesis    []byte // nodes with a different genesis block are in a different chain
+	BestHeight int
+	Work       []byte // accumulated work of the last block of the sender, a big endian number
+	AddrFrom   string
+}
+
+// cmdToBytes : the command padded with zeros to commandLength bytes
+func cmdToBytes(cmd string) []byte {
+	var bytes [commandLength]byte
+
+	copy(bytes[:], cmd)
+
+	return bytes[:]
+}
+
+// bytesToCmd : the command without the padding
+func bytesToCmd(bytes []byte) string {
+	var cmd []byte
+
+	for _, b := range bytes {
+		if b != 0x0 {
+			cmd = append(cmd, b)
+		}
+	}
+
+	return string(cmd)
+}
+
+// encodeMessage : the command followed by the gob encoding of the payload
+func encodeMessage(cmd string, payload interface{}) ([]byte, error) {
+	var buff bytes.Buffer
+
+	buff.Write(cmdToBytes(cmd))
+	if err := gob.NewEncoder(&buff).Encode(payload); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+// decodePayload : decode the payload of a message into v
+func decodePayload(request []byte, v interface{}) error {
+	if err := gob.NewDecoder(bytes.NewReader(request[commandLength:])).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", bytesToCmd(request[:commandLength]), err)
+	}
+
+	return nil
+}
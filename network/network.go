@@ -0,0 +1,731 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Dieg0Code/Blockchain.go/blockchain"
+)
+
+const (
+	protocol       = "tcp"
+	nodeVersion    = 3                // 2 sends the blocks and transactions with the binary format instead of gob, 3 hashes them with it
+	maxInvItems    = 500              // block hashes sent in a single inv message
+	maxMessageSize = 32 << 20         // bytes read from a connection at most
+	dialTimeout    = 5 * time.Second  // time to connect to a peer
+	ioTimeout      = 30 * time.Second // time to send or receive a whole message
+)
+
+/*
+	When a node starts it sends its version to the nodes it knows. A node whose chain has less
+	work asks for the hashes of the blocks it's missing with getblocks, and then asks for the
+	blocks one by one with getdata, connecting each of them on top of its chain. The blocks still
+	to ask for are kept for each peer, so two peers can send their blocks at the same time. New transactions and
+	blocks are announced to the peers with inv, and the peers ask for the ones they don't have.
+	A node with a miner address mines the transactions it receives, and stops mining when a peer
+	sends a block that extends the chain first.
+
+	Esp:
+
+	Cuando un nodo inicia envía su versión a los nodos que conoce. Un nodo cuya cadena tiene menos
+	trabajo pide los hashes de los bloques que le faltan con getblocks, y luego pide los bloques uno
+	por uno con getdata, conectando cada uno encima de su cadena. Los bloques que faltan pedir se
+	guardan por cada peer, asi dos peers pueden enviar sus bloques al mismo tiempo. Las transacciones y bloques nuevos se
+	anuncian a los peers con inv, y los peers piden los que no tienen. Un nodo con una dirección de
+	minero mina las transacciones que recibe, y deja de minar cuando un peer envía un bloque que
+	extiende la cadena primero.
+*/
+
+// Server : a node of the network, every server has its own chain so several nodes can run in the same process
+type Server struct {
+	Address      string // host:port where the node listens, the peers reach the node at this address
+	MinerAddress string // when it's set the node mines the transactions it receives
+	MinTxs       int    // transactions needed in the pool before a block is mined
+	Logger       *log.Logger
+
+	chain   *blockchain.BlockChain
+	chainMu sync.Mutex // the chain is used by the handlers and the miner at the same time
+	pool    *blockchain.Mempool
+	genesis []byte
+
+	mu           sync.Mutex
+	knownNodes   map[string]bool
+	inTransit    map[string]*transit // blocks being downloaded from each peer
+	mining       bool
+	cancelMining context.CancelFunc
+
+	listener net.Listener
+	ctx      context.Context // cancelled when the server is closed
+	stop     context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// transit : the blocks of an inv that are still to be asked for to the peer that sent it
+type transit struct {
+	hashes [][]byte
+	batch  int // size of the inv, a full batch means the peer has more blocks
+}
+
+// NewServer : node that listens on the address and syncs the chain with the peers
+func NewServer(address string, chain *blockchain.BlockChain, peers []string) (*Server, error) {
+	genesis, err := chain.GenesisHash()
+	if err != nil {
+		return nil, err
+	}
+	pool, err := blockchain.NewMempool(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Address:    address,
+		MinTxs:     1,
+		Logger:     log.New(os.Stderr, fmt.Sprintf("[%s] ", address), log.LstdFlags),
+		chain:      chain,
+		pool:       pool,
+		genesis:    genesis,
+		knownNodes: make(map[string]bool),
+		inTransit:  make(map[string]*transit),
+	}
+	for _, peer := range peers {
+		if peer != "" && peer != address {
+			s.knownNodes[peer] = true
+		}
+	}
+
+	return s, nil
+}
+
+// Start : listen for connections and send our version to the known nodes
+func (s *Server) Start() error {
+	ln, err := net.Listen(protocol, s.Address)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+
+	if host, port, err := net.SplitHostPort(s.Address); err == nil && port == "0" { // a random port was requested
+		_, port, _ = net.SplitHostPort(ln.Addr().String())
+		s.Address = net.JoinHostPort(host, port)
+		s.Logger.SetPrefix(fmt.Sprintf("[%s] ", s.Address))
+	}
+
+	s.ctx, s.stop = context.WithCancel(context.Background())
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	for _, node := range s.Peers() {
+		if err := s.sendVersion(node); err != nil {
+			s.Logger.Printf("Peer %s is not available: %v", node, err)
+		}
+	}
+
+	return nil
+}
+
+// Close : stop listening and wait until every message being handled and the miner are done
+func (s *Server) Close() error {
+	if s.listener == nil { // never started
+		return nil
+	}
+	s.stop()
+	err := s.listener.Close()
+	s.wg.Wait()
+
+	return err
+}
+
+// Peers : the addresses of the nodes known by the server
+func (s *Server) Peers() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var nodes []string
+	for node := range s.knownNodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	return nodes
+}
+
+// BestHeight : height of the last block of the chain of the server
+func (s *Server) BestHeight() (int, error) {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	return s.chain.GetBestHeight()
+}
+
+// tipWork : height and accumulated work of the last block of the chain of the server
+func (s *Server) tipWork() (int, *big.Int, error) {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	height, err := s.chain.GetBestHeight()
+	if err != nil {
+		return 0, nil, err
+	}
+	work, err := s.chain.ChainWork(s.chain.LastHash)
+
+	return height, work, err
+}
+
+// MempoolSize : number of transactions waiting to be mined
+func (s *Server) MempoolSize() int {
+	return s.pool.Count()
+}
+
+// WithChain : run fn while no handler or miner is using the chain, fn must not call other methods of the server
+func (s *Server) WithChain(fn func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error) error {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	return fn(s.chain, s.pool)
+}
+
+// SubmitTx : add a transaction created by a client of the node to the pool and relay it to the peers
+func (s *Server) SubmitTx(tx *blockchain.Transaction) error {
+	return s.addTx(tx, "")
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.ctx.Done(): // the listener was closed by Close
+				return
+			default:
+			}
+			s.Logger.Printf("Accept: %v", err)
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConnection(conn)
+		}()
+	}
+}
+
+func (s *Server) handleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(ioTimeout))
+	request, err := ioutil.ReadAll(io.LimitReader(conn, maxMessageSize))
+	if err != nil {
+		s.Logger.Printf("Read: %v", err)
+		return
+	}
+	if len(request) < commandLength {
+		s.Logger.Printf("Message too short: %d bytes", len(request))
+		return
+	}
+
+	command := bytesToCmd(request[:commandLength])
+
+	switch command {
+	case "addr":
+		err = s.handleAddr(request)
+	case "block":
+		err = s.handleBlock(request)
+	case "inv":
+		err = s.handleInv(request)
+	case "getblocks":
+		err = s.handleGetBlocks(request)
+	case "getdata":
+		err = s.handleGetData(request)
+	case "tx":
+		err = s.handleTx(request)
+	case "version":
+		err = s.handleVersion(request)
+	default:
+		err = fmt.Errorf("unknown command %q", command)
+	}
+	if err != nil {
+		s.Logger.Printf("%s: %v", command, err)
+	}
+}
+
+// addNode : remember the address of a node, reports if it was unknown
+func (s *Server) addNode(addr string) bool {
+	if addr == "" || addr == s.Address { // wallets that only send a transaction don't listen
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.knownNodes[addr] {
+		return false
+	}
+	s.knownNodes[addr] = true
+
+	return true
+}
+
+// sendData : send a message to the node, a node that can't be reached is forgotten
+func (s *Server) sendData(addr string, data []byte) error {
+	if err := sendData(addr, data); err != nil {
+		s.mu.Lock()
+		delete(s.knownNodes, addr)
+		s.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+func sendData(addr string, data []byte) error {
+	conn, err := net.DialTimeout(protocol, addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(ioTimeout))
+	_, err = conn.Write(data)
+
+	return err
+}
+
+func (s *Server) send(addr, cmd string, payload interface{}) error {
+	data, err := encodeMessage(cmd, payload)
+	if err != nil {
+		return err
+	}
+
+	return s.sendData(addr, data)
+}
+
+// broadcast : send the message to every known node except the one in skip
+func (s *Server) broadcast(skip, cmd string, payload interface{}) {
+	for _, node := range s.Peers() {
+		if node == skip {
+			continue
+		}
+		if err := s.send(node, cmd, payload); err != nil {
+			s.Logger.Printf("Send %s to %s: %v", cmd, node, err)
+		}
+	}
+}
+
+func (s *Server) sendVersion(addr string) error {
+	height, work, err := s.tipWork()
+	if err != nil {
+		return err
+	}
+
+	return s.send(addr, "version", Version{nodeVersion, s.genesis, height, work.Bytes(), s.Address})
+}
+
+func (s *Server) sendGetBlocks(addr string) error {
+	s.chainMu.Lock()
+	locator := s.chain.LastHash
+	s.chainMu.Unlock()
+
+	return s.send(addr, "getblocks", GetBlocks{s.Address, locator})
+}
+
+func (s *Server) sendGetData(addr, kind string, id []byte) error {
+	return s.send(addr, "getdata", GetData{s.Address, kind, id})
+}
+
+// SendTx : send a transaction to a node so it's relayed to the network and mined
+func SendTx(addr string, tx *blockchain.Transaction) error {
+	encoded, err := tx.Serialize()
+	if err != nil {
+		return err
+	}
+	data, err := encodeMessage("tx", Tx{"", encoded})
+	if err != nil {
+		return err
+	}
+
+	return sendData(addr, data)
+}
+
+func (s *Server) handleVersion(request []byte) error {
+	var payload Version
+	if err := decodePayload(request, &payload); err != nil {
+		return err
+	}
+
+	if payload.Version < nodeVersion { // an older node can't decode or validate our blocks
+		return fmt.Errorf("node %s has the old version %d, expected %d", payload.AddrFrom, payload.Version, nodeVersion)
+	}
+	if !bytes.Equal(payload.Genesis, s.genesis) {
+		return fmt.Errorf("node %s has a different genesis block %x", payload.AddrFrom, payload.Genesis)
+	}
+
+	_, myWork, err := s.tipWork()
+	if err != nil {
+		return err
+	}
+
+	switch myWork.Cmp(new(big.Int).SetBytes(payload.Work)) { // the chain with the most work wins, not the longest one
+	case -1:
+		err = s.sendGetBlocks(payload.AddrFrom)
+	case 1:
+		err = s.sendVersion(payload.AddrFrom)
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.addNode(payload.AddrFrom) { // tell the new node about the rest of the network
+		return s.send(payload.AddrFrom, "addr", Addr{s.Peers()})
+	}
+
+	return nil
+}
+
+func (s *Server) handleAddr(request []byte) error {
+	var payload Addr
+	if err := decodePayload(request, &payload); err != nil {
+		return err
+	}
+
+	for _, node := range payload.AddrList {
+		if s.addNode(node) {
+			if err := s.sendVersion(node); err != nil {
+				s.Logger.Printf("Peer %s is not available: %v", node, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) handleGetBlocks(request []byte) error {
+	var payload GetBlocks
+	if err := decodePayload(request, &payload); err != nil {
+		return err
+	}
+
+	s.chainMu.Lock()
+	hashes, err := s.chain.BlockHashesAfter(payload.Locator, maxInvItems)
+	s.chainMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return s.send(payload.AddrFrom, "inv", Inv{s.Address, blockType, hashes})
+}
+
+func (s *Server) handleInv(request []byte) error {
+	var payload Inv
+	if err := decodePayload(request, &payload); err != nil {
+		return err
+	}
+
+	switch payload.Type {
+	case blockType:
+		var missing [][]byte
+		for _, hash := range payload.Items {
+			s.chainMu.Lock()
+			found, err := s.chain.HasBlock(hash)
+			s.chainMu.Unlock()
+			if err != nil {
+				return err
+			}
+			if !found {
+				missing = append(missing, hash)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		s.mu.Lock()
+		s.inTransit[payload.AddrFrom] = &transit{missing[1:], len(payload.Items)}
+		s.mu.Unlock()
+
+		return s.sendGetData(payload.AddrFrom, blockType, missing[0])
+
+	case txType:
+		for _, txID := range payload.Items {
+			if _, known := s.pool.Get(txID); known {
+				continue
+			}
+			if err := s.sendGetData(payload.AddrFrom, txType, txID); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown inventory type %q", payload.Type)
+	}
+}
+
+func (s *Server) handleGetData(request []byte) error {
+	var payload GetData
+	if err := decodePayload(request, &payload); err != nil {
+		return err
+	}
+
+	switch payload.Type {
+	case blockType:
+		s.chainMu.Lock()
+		block, err := s.chain.GetBlock(payload.ID)
+		s.chainMu.Unlock()
+		if err != nil {
+			return err
+		}
+		encoded, err := block.Serialize()
+		if err != nil {
+			return err
+		}
+		return s.send(payload.AddrFrom, "block", Block{s.Address, encoded})
+
+	case txType:
+		tx, ok := s.pool.Get(payload.ID)
+		if !ok {
+			return fmt.Errorf("%w: %x", blockchain.ErrTxNotFound, payload.ID)
+		}
+		encoded, err := tx.Serialize()
+		if err != nil {
+			return err
+		}
+		return s.send(payload.AddrFrom, "tx", Tx{s.Address, encoded})
+
+	default:
+		return fmt.Errorf("unknown inventory type %q", payload.Type)
+	}
+}
+
+func (s *Server) handleBlock(request []byte) error {
+	var payload Block
+	if err := decodePayload(request, &payload); err != nil {
+		return err
+	}
+
+	block, err := blockchain.Deserialize(payload.Block)
+	if err != nil {
+		return err
+	}
+
+	var change *blockchain.TipChange
+	s.chainMu.Lock()
+	found, err := s.chain.HasBlock(block.Hash)
+	if err == nil && !found {
+		change, err = s.chain.AcceptBlock(block)
+	}
+	s.chainMu.Unlock()
+	if found { // it was relayed to us by more than one peer
+		return nil
+	}
+
+	if err != nil { // the rest of the blocks of the peer are asked for again, or not at all if it sends invalid blocks
+		s.mu.Lock()
+		delete(s.inTransit, payload.AddrFrom)
+		s.mu.Unlock()
+	}
+	if errors.Is(err, blockchain.ErrOrphanBlock) { // we are missing the blocks before this one, the chain holds it until they arrive
+		return s.sendGetBlocks(payload.AddrFrom)
+	}
+	if err != nil {
+		return err
+	}
+
+	if change == nil {
+		s.Logger.Printf("Stored block %x at height %d on a side branch", block.Hash, block.Height)
+	} else {
+		s.Logger.Printf("Added block %x at height %d", block.Hash, block.Height)
+		s.tipChanged(change)
+	}
+
+	s.mu.Lock()
+	var next []byte
+	moreBlocks := false
+	if pending, ok := s.inTransit[payload.AddrFrom]; ok {
+		if len(pending.hashes) > 0 {
+			next = pending.hashes[0]
+			pending.hashes = pending.hashes[1:]
+		} else {
+			moreBlocks = pending.batch == maxInvItems
+			delete(s.inTransit, payload.AddrFrom)
+		}
+	}
+	s.mu.Unlock()
+
+	if next != nil {
+		return s.sendGetData(payload.AddrFrom, blockType, next)
+	}
+	if moreBlocks { // the peer had more blocks than fit in one inv
+		return s.sendGetBlocks(payload.AddrFrom)
+	}
+
+	s.broadcast(payload.AddrFrom, "inv", Inv{s.Address, blockType, [][]byte{block.Hash}}) // our chain is up to date, tell the rest of the network
+	return nil
+}
+
+// tipChanged : update the pool after the main chain moved and abort the block we are mining
+func (s *Server) tipChanged(change *blockchain.TipChange) {
+	if len(change.Disconnected) > 0 {
+		s.Logger.Printf("Reorganized the chain, %d blocks disconnected and %d blocks connected", len(change.Disconnected), len(change.Connected))
+	}
+
+	s.chainMu.Lock() // the pool validates the transactions of the disconnected blocks against the chain
+	err := s.pool.Reorganize(change)
+	s.chainMu.Unlock()
+	if err != nil {
+		s.Logger.Printf("Mempool: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancelMining != nil {
+		s.cancelMining()
+	}
+}
+
+func (s *Server) handleTx(request []byte) error {
+	var payload Tx
+	if err := decodePayload(request, &payload); err != nil {
+		return err
+	}
+
+	tx, err := blockchain.DeserializeTransaction(payload.Transaction)
+	if err != nil {
+		return err
+	}
+
+	err = s.addTx(&tx, payload.AddrFrom)
+	if errors.Is(err, blockchain.ErrKnownTx) { // it was relayed to us by more than one peer
+		return nil
+	}
+
+	return err
+}
+
+// addTx : add the transaction to the pool, announce it to every peer except the one that sent it and mine it
+func (s *Server) addTx(tx *blockchain.Transaction, from string) error {
+	s.chainMu.Lock()
+	err := s.pool.Add(tx)
+	s.chainMu.Unlock()
+	if err != nil {
+		return err
+	}
+	s.Logger.Printf("Added transaction %x to the pool", tx.ID)
+
+	s.broadcast(from, "inv", Inv{s.Address, txType, [][]byte{tx.ID}})
+	s.startMining()
+
+	return nil
+}
+
+// startMining : start the miner if the node has a miner address and it's not already mining
+func (s *Server) startMining() {
+	if s.MinerAddress == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.mining || s.pool.Count() < s.MinTxs {
+		return
+	}
+	s.mining = true
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.mineTransactions()
+	}()
+}
+
+// mineTransactions : mine blocks with the transactions of the pool until there are not enough of them
+func (s *Server) mineTransactions() {
+	for {
+		s.mu.Lock()
+		if s.pool.Count() < s.MinTxs || s.ctx.Err() != nil {
+			s.mining = false
+			s.cancelMining = nil
+			s.mu.Unlock()
+			return
+		}
+		txs := s.pool.Template(0)
+		ctx, cancel := context.WithCancel(s.ctx)
+		s.cancelMining = cancel
+		s.mu.Unlock()
+
+		block, change, err := s.mineBlock(ctx, txs)
+		cancel()
+
+		switch {
+		case err == nil:
+			s.Logger.Printf("Mined block %x at height %d", block.Hash, block.Height)
+			s.tipChanged(change)
+			s.broadcast("", "inv", Inv{s.Address, blockType, [][]byte{block.Hash}})
+		case errors.Is(err, context.Canceled), errors.Is(err, blockchain.ErrStaleBlock):
+			// a peer extended the chain first, the pool was updated so we try again
+		default:
+			s.Logger.Printf("Mining: %v", err)
+			for _, tx := range txs { // the transactions can't be mined on top of the current chain
+				if err := s.pool.Remove(tx.ID); err != nil {
+					s.Logger.Printf("Mempool: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// mineBlock : mine a block with the coinbase of the miner and the transactions, the chain is only locked
+// while the template is built and while the block is connected
+func (s *Server) mineBlock(ctx context.Context, txs []*blockchain.Transaction) (*blockchain.Block, *blockchain.TipChange, error) {
+	s.chainMu.Lock()
+
+	cbTx, err := s.chain.NewCoinbase(s.MinerAddress, txs)
+	if err != nil {
+		s.chainMu.Unlock()
+		return nil, nil, err
+	}
+
+	block, err := s.chain.NewBlockTemplate(append([]*blockchain.Transaction{cbTx}, txs...))
+	miner := s.chain.Miner
+	s.chainMu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce, hash, err := blockchain.NewProof(block).Mine(ctx, miner)
+	if err != nil {
+		return nil, nil, err
+	}
+	block.Nonce = nonce
+	block.Hash = hash
+
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	change, err := s.chain.AcceptBlock(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	if change == nil { // a peer extended the chain first, our block is kept on a side branch
+		return nil, nil, blockchain.ErrStaleBlock
+	}
+
+	return block, change, nil
+}
@@ -0,0 +1,240 @@
+package network
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/Dieg0Code/Blockchain.go/blockchain"
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+// testNetwork : chains in memory that share the genesis block mined for the wallet
+type testNetwork struct {
+	t       *testing.T
+	wallet  *wallet.Wallet
+	genesis []byte // bootstrap file with the genesis block
+}
+
+func newTestNetwork(t *testing.T) *testNetwork {
+	t.Helper()
+
+	w, err := wallet.MakeWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := blockchain.InitBlockChain(string(w.Address()), blockchain.Options{Network: "test", Store: blockchain.NewMemoryStore()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	var genesis bytes.Buffer
+	if _, err := chain.ExportChain(&genesis); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testNetwork{t, w, genesis.Bytes()}
+}
+
+// chain : a new chain with the genesis block of the network
+func (n *testNetwork) chain() *blockchain.BlockChain {
+	n.t.Helper()
+
+	opts := blockchain.Options{Network: "test", Store: blockchain.NewMemoryStore()}
+	if _, err := blockchain.ImportChain(bytes.NewReader(n.genesis), opts, nil); err != nil {
+		n.t.Fatal(err)
+	}
+	chain, err := blockchain.ContinueBlockChain(opts)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	n.t.Cleanup(func() { chain.Close() })
+
+	return chain
+}
+
+// node : a started node on a random port of the loopback interface
+func (n *testNetwork) node(chain *blockchain.BlockChain, peers ...string) *Server {
+	n.t.Helper()
+
+	s, err := NewServer("127.0.0.1:0", chain, peers)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	s.Logger = log.New(ioutil.Discard, "", 0)
+	if err := s.Start(); err != nil {
+		n.t.Fatal(err)
+	}
+	n.t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// mine : add blocks that pay the wallet to the chain
+func (n *testNetwork) mine(chain *blockchain.BlockChain, blocks int) {
+	n.t.Helper()
+
+	for i := 0; i < blocks; i++ {
+		cbTx, err := chain.NewCoinbase(string(n.wallet.Address()), nil)
+		if err != nil {
+			n.t.Fatal(err)
+		}
+		if _, err := chain.AddBlock([]*blockchain.Transaction{cbTx}); err != nil {
+			n.t.Fatal(err)
+		}
+	}
+}
+
+// mineSlowly : add blocks that pay the wallet to the chain with timestamps that are the seconds apart
+func (n *testNetwork) mineSlowly(chain *blockchain.BlockChain, blocks int, seconds int64) {
+	n.t.Helper()
+
+	for i := 0; i < blocks; i++ {
+		prev, err := chain.GetBlock(chain.LastHash)
+		if err != nil {
+			n.t.Fatal(err)
+		}
+		cbTx, err := chain.NewCoinbase(string(n.wallet.Address()), nil)
+		if err != nil {
+			n.t.Fatal(err)
+		}
+		block, err := chain.NewBlockTemplate([]*blockchain.Transaction{cbTx})
+		if err != nil {
+			n.t.Fatal(err)
+		}
+		block.Timestamp = prev.Timestamp + seconds
+		if block.Nonce, block.Hash, err = blockchain.NewProof(block).Run(); err != nil {
+			n.t.Fatal(err)
+		}
+		if _, err := chain.AcceptBlock(block); err != nil {
+			n.t.Fatal(err)
+		}
+	}
+}
+
+// tip : hash of the last block of the node
+func tip(s *Server) []byte {
+	var hash []byte
+	s.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		hash = chain.LastHash
+		return nil
+	})
+
+	return hash
+}
+
+// waitFor : wait until the condition holds, the test fails after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(15 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// hasPeer : the node knows the address
+func hasPeer(s *Server, addr string) bool {
+	for _, peer := range s.Peers() {
+		if peer == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestNodesSyncAndRelay(t *testing.T) {
+	n := newTestNetwork(t)
+
+	chainA := n.chain()
+	n.mine(chainA, 3)
+	a := n.node(chainA)
+	a.MinerAddress = string(n.wallet.Address())
+
+	b := n.node(n.chain(), a.Address)
+	c := n.node(n.chain(), a.Address)
+
+	waitFor(t, "the handshake", func() bool {
+		return hasPeer(a, b.Address) && hasPeer(a, c.Address) && hasPeer(b, a.Address) && hasPeer(c, a.Address)
+	})
+	waitFor(t, "the nodes to download the chain", func() bool {
+		return bytes.Equal(tip(b), tip(a)) && bytes.Equal(tip(c), tip(a))
+	})
+
+	var tx *blockchain.Transaction
+	err := b.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		var err error
+		tx, err = blockchain.NewTransaction(n.wallet, string(n.wallet.Address()), 10, 1, &blockchain.UTXOSet{Blockchain: chain})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SubmitTx(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the block with the transaction", func() bool {
+		if height, err := a.BestHeight(); err != nil || height != 4 {
+			return false
+		}
+		return bytes.Equal(tip(b), tip(a)) && bytes.Equal(tip(c), tip(a))
+	})
+
+	a.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		block, err := chain.GetBlock(chain.LastHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(block.Transactions) != 2 || !bytes.Equal(block.Transactions[1].ID, tx.ID) {
+			t.Errorf("the mined block doesn't have the transaction %x", tx.ID)
+		}
+		return nil
+	})
+	waitFor(t, "the pools to drop the mined transaction", func() bool {
+		return a.MempoolSize() == 0 && b.MempoolSize() == 0 && c.MempoolSize() == 0
+	})
+}
+
+func TestNodeFollowsTheChainWithMoreWork(t *testing.T) {
+	n := newTestNetwork(t)
+
+	heavy := n.chain() // blocks found fast, the difficulty goes up at the first retarget
+	n.mine(heavy, 12)
+	long := n.chain() // more blocks found slowly, the difficulty goes down
+	n.mineSlowly(long, 14, 60)
+
+	a := n.node(long)
+	b := n.node(heavy, a.Address)
+
+	waitFor(t, "the longer chain to reorganize to the one with more work", func() bool {
+		return bytes.Equal(tip(a), tip(b))
+	})
+	if height, err := a.BestHeight(); err != nil || height != 12 {
+		t.Errorf("height %d, %v, expected 12", height, err)
+	}
+}
+
+func TestNodeRefusesOlderVersions(t *testing.T) {
+	n := newTestNetwork(t)
+	a := n.node(n.chain())
+
+	const old = "127.0.0.1:1" // never dialed, the version is refused first
+	request, err := encodeMessage("version", Version{nodeVersion - 1, a.genesis, 0, nil, old})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.handleVersion(request); err == nil {
+		t.Error("the version of an older node was accepted")
+	}
+	if hasPeer(a, old) {
+		t.Error("the older node was added to the peers")
+	}
+}
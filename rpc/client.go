@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// Client : calls the methods of a JSON-RPC server
+type Client struct {
+	Address    string // host:port of the server
+	Token      string // token of the cookie file of the server
+	HTTPClient *http.Client
+
+	nextID int64
+}
+
+// NewClient : client of the server at the address that authenticates with the token
+func NewClient(address, token string) *Client {
+	return &Client{
+		Address:    address,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: ioTimeout},
+	}
+}
+
+// ReadCookie : the token written to the cookie file by a running server
+func ReadCookie(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("the cookie file of the daemon can't be read, is it running? %w", err)
+	}
+
+	return string(bytes.TrimSpace(data)), nil
+}
+
+// Call : call the method with the params and decode its result into result, an error sent by the server is returned as an *Error
+func (c *Client) Call(method string, params, result interface{}) error {
+	request := Request{
+		Version: version,
+		Method:  method,
+		ID:      json.RawMessage(strconv.FormatInt(atomic.AddInt64(&c.nextID, 1), 10)),
+	}
+	if params != nil {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		request.Params = encoded
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "http://"+c.Address+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	var response Response
+	if err := json.Unmarshal(data, &response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(response.Result, result)
+}
@@ -0,0 +1,371 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Dieg0Code/Blockchain.go/blockchain"
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+// BlockParams : params of getblock, the hex encoded hash of the block
+type BlockParams struct {
+	Hash string `json:"hash"`
+}
+
+// TxParams : params of gettransaction, the hex encoded ID of the transaction
+type TxParams struct {
+	TxID string `json:"txid"`
+}
+
+// HeightParams : params of getblockbyheight
+type HeightParams struct {
+	Height *int `json:"height"`
+}
+
+// AddressParams : params of getbalance
+type AddressParams struct {
+	Address string `json:"address"`
+}
+
+// HistoryParams : params of gethistory, skips the newest offset transactions and returns at most limit, all when it's 0
+type HistoryParams struct {
+	Address string `json:"address"`
+	Offset  int    `json:"offset"`
+	Limit   int    `json:"limit"`
+}
+
+// ProofParams : params of gettxproof, the hex encoded ID of the transaction and hash of its block
+type ProofParams struct {
+	TxID  string `json:"txid"`
+	Block string `json:"block"`
+}
+
+// SendParams : params of send, the daemon signs the transaction with the wallet of the sender
+type SendParams struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+	Fee    int    `json:"fee"`
+}
+
+// RawTxParams : params of sendtransaction, a serialized transaction that is already signed
+type RawTxParams struct {
+	Hex string `json:"hex"`
+}
+
+// decodeHash : the hex encoded hash of the param with the name
+func decodeHash(name, value string) ([]byte, error) {
+	if value == "" {
+		return nil, invalidParams("missing %s", name)
+	}
+	hash, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, invalidParams("%s is not hex: %v", name, err)
+	}
+
+	return hash, nil
+}
+
+func (s *Server) getBalance(params json.RawMessage) (interface{}, error) {
+	var p AddressParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	pubKeyHash, err := wallet.PubKeyHashFromAddress(p.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := 0
+	err = s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		UTXOs, err := UTXOSet.FindUTXO(pubKeyHash)
+		for _, out := range UTXOs {
+			balance += out.Value
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return Balance{p.Address, balance}, nil
+}
+
+func (s *Server) getBestBlockHash(params json.RawMessage) (interface{}, error) {
+	var hash []byte
+	err := s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		hash = chain.LastHash
+		return nil
+	})
+
+	return hex.EncodeToString(hash), err
+}
+
+func (s *Server) getBlock(params json.RawMessage) (interface{}, error) {
+	var p BlockParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	hash, err := decodeHash("hash", p.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var info BlockInfo
+	err = s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		block, err := chain.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		info = BlockInfo{NewBlock(&block), chain.ValidateHeader(&block) == nil}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (s *Server) getBlockByHeight(params json.RawMessage) (interface{}, error) {
+	var p HeightParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Height == nil || *p.Height < 0 {
+		return nil, invalidParams("missing height")
+	}
+
+	var info BlockInfo
+	err := s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		block, err := chain.GetBlockByHeight(*p.Height)
+		if err != nil {
+			return err
+		}
+		info = BlockInfo{NewBlock(&block), chain.ValidateHeader(&block) == nil}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (s *Server) getChainInfo(params json.RawMessage) (interface{}, error) {
+	info := ChainInfo{
+		Network: s.options.Network,
+		Peers:   append([]string{}, s.node.Peers()...), // an empty list instead of null
+	}
+
+	err := s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		tip, err := chain.GetBlock(chain.LastHash)
+		if err != nil {
+			return err
+		}
+		work, err := chain.ChainWork(tip.Hash)
+		if err != nil {
+			return err
+		}
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		supply, err := UTXOSet.TotalValue()
+		if err != nil {
+			return err
+		}
+
+		info.Height = tip.Height
+		info.BestBlockHash = hex.EncodeToString(tip.Hash)
+		info.Difficulty = tip.Difficulty
+		info.ChainWork = work.Text(16)
+		info.Supply = supply
+		info.MempoolSize = pool.Count()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (s *Server) getHistory(params json.RawMessage) (interface{}, error) {
+	var p HistoryParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Offset < 0 || p.Limit < 0 {
+		return nil, invalidParams("the offset and the limit can't be negative")
+	}
+	pubKeyHash, err := wallet.PubKeyHashFromAddress(p.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []blockchain.AddressTx
+	err = s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		history, err = chain.AddressHistory(pubKeyHash, p.Offset, p.Limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]AddressTx, 0, len(history))
+	for _, entry := range history {
+		views = append(views, NewAddressTx(entry))
+	}
+
+	return views, nil
+}
+
+func (s *Server) getMempool(params json.RawMessage) (interface{}, error) {
+	var entries []MempoolEntry
+	err := s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		entries = make([]MempoolEntry, 0, pool.Count())
+		for _, tx := range pool.Transactions() {
+			fee, _ := pool.Fee(tx.ID)
+			entries = append(entries, MempoolEntry{NewTransaction(tx), fee})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (s *Server) getTransaction(params json.RawMessage) (interface{}, error) {
+	var p TxParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	ID, err := decodeHash("txid", p.TxID)
+	if err != nil {
+		return nil, err
+	}
+
+	var info *TransactionInfo
+	err = s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		if tx, ok := pool.Get(ID); ok { // not mined yet
+			info = &TransactionInfo{Transaction: NewTransaction(tx), Height: -1}
+			return nil
+		}
+
+		best, err := chain.GetBestHeight()
+		if err != nil {
+			return err
+		}
+
+		tx, block, err := chain.FindTransactionBlock(ID)
+		if err != nil {
+			return err
+		}
+		info = &TransactionInfo{
+			Transaction:   NewTransaction(tx),
+			BlockHash:     hex.EncodeToString(block.Hash),
+			Height:        block.Height,
+			Confirmations: best - block.Height + 1,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (s *Server) getTxProof(params json.RawMessage) (interface{}, error) {
+	var p ProofParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	ID, err := decodeHash("txid", p.TxID)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := decodeHash("block", p.Block)
+	if err != nil {
+		return nil, err
+	}
+
+	var proof *blockchain.MerkleProof
+	err = s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		proof, err = chain.ProveTransaction(ID, hash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+func (s *Server) send(params json.RawMessage) (interface{}, error) {
+	var p SendParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Amount <= 0 || p.Fee < 0 {
+		return nil, invalidParams("the amount must be positive and the fee can't be negative")
+	}
+	if !wallet.ValidateAddress(p.To) {
+		return nil, fmt.Errorf("%w %q", wallet.ErrInvalidAddress, p.To)
+	}
+	if !wallet.ValidateAddress(p.From) {
+		return nil, fmt.Errorf("%w %q", wallet.ErrInvalidAddress, p.From)
+	}
+
+	wallets, err := wallet.CreateWallets(s.options.WalletFile()) // read on every call, so the wallets created after the daemon started are found
+	if err != nil {
+		return nil, err
+	}
+	w, err := wallets.GetWallet(p.From)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *blockchain.Transaction
+	err = s.node.WithChain(func(chain *blockchain.BlockChain, pool *blockchain.Mempool) error {
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain, Mempool: pool}
+		tx, err = blockchain.NewTransaction(&w, p.To, p.Amount, p.Fee, &UTXOSet)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.node.SubmitTx(tx); err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+func (s *Server) sendTransaction(params json.RawMessage) (interface{}, error) {
+	var p RawTxParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	data, err := decodeHash("hex", p.Hex)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := blockchain.DeserializeTransaction(data)
+	if err != nil {
+		return nil, invalidParams("the transaction can't be decoded: %v", err)
+	}
+
+	if err := s.node.SubmitTx(&tx); err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
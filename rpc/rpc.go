@@ -0,0 +1,378 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dieg0Code/Blockchain.go/blockchain"
+	"github.com/Dieg0Code/Blockchain.go/network"
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+/*
+	The daemon answers JSON-RPC 2.0 requests sent with HTTP POST, so the chain of a running node
+	can be used by other programs while the node holds the badger database. The params of every
+	method are an object with named fields, a request without an ID is a notification and gets no
+	response, and several requests can be sent together in a batch. The errors of the blockchain
+	and wallet packages are sent with their own codes, the client translates them back so the
+	caller can still compare them with errors.Is.
+
+	The send method spends the coins of the wallets of the node, so every request must have the
+	header "Authorization: Bearer TOKEN" and the "Content-Type: application/json" header, a web
+	page can't send that kind of request to another origin without asking first. The token is
+	random, it's written to the cookie file when the server starts and removed when it stops,
+	only the user of the node can read it, and the CLI reads it from the same network directory.
+
+	Esp:
+
+	El daemon responde peticiones JSON-RPC 2.0 enviadas con HTTP POST, asi la cadena de un nodo en
+	ejecución puede ser usada por otros programas mientras el nodo tiene la base de datos badger.
+	Los params de cada método son un objeto con campos con nombre, una petición sin ID es una
+	notificación y no recibe respuesta, y se pueden enviar varias peticiones juntas en un batch.
+	Los errores de los paquetes blockchain y wallet se envían con sus propios códigos, el cliente
+	los traduce de vuelta para que el que llama los pueda seguir comparando con errors.Is.
+
+	El método send gasta las monedas de las billeteras del nodo, asi que cada petición debe tener
+	el header "Authorization: Bearer TOKEN" y el header "Content-Type: application/json", una
+	página web no puede enviar ese tipo de petición a otro origen sin preguntar primero. El token
+	es aleatorio, se escribe en el archivo cookie cuando el servidor inicia y se borra cuando se
+	detiene, solo el usuario del nodo lo puede leer, y la CLI lo lee del mismo directorio de red.
+*/
+
+const (
+	version         = "2.0"
+	maxRequestSize  = 1 << 20          // bytes read from the body of a request at most
+	maxResponseSize = 32 << 20         // bytes read from the body of a response at most
+	ioTimeout       = 30 * time.Second // time to read a request or write a response
+	tokenSize       = 32               // random bytes of the token of the cookie file
+)
+
+// Error codes defined by JSON-RPC 2.0
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Error codes of the errors of the blockchain and wallet packages
+const (
+	CodeBlockNotFound     = -32001
+	CodeTxNotFound        = -32002
+	CodeInvalidTx         = -32003
+	CodeDoubleSpend       = -32004
+	CodeKnownTx           = -32005
+	CodeInsufficientFunds = -32006
+	CodeInvalidAddress    = -32007
+	CodeWalletNotFound    = -32008
+	CodeInvalidBlock      = -32009
+)
+
+// codeErrors : the error of the packages sent with each code
+var codeErrors = map[int]error{
+	CodeBlockNotFound:     blockchain.ErrBlockNotFound,
+	CodeTxNotFound:        blockchain.ErrTxNotFound,
+	CodeInvalidTx:         blockchain.ErrInvalidTx,
+	CodeDoubleSpend:       blockchain.ErrDoubleSpend,
+	CodeKnownTx:           blockchain.ErrKnownTx,
+	CodeInsufficientFunds: blockchain.ErrInsufficientFunds,
+	CodeInvalidAddress:    wallet.ErrInvalidAddress,
+	CodeWalletNotFound:    wallet.ErrWalletNotFound,
+	CodeInvalidBlock:      blockchain.ErrInvalidBlock,
+}
+
+// Request : a call to a method, without an ID it's a notification
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// Response : the result of a request or the error that it caused
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Error : a failed request
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// Unwrap : the error of the blockchain or wallet packages sent with the code, if any
+func (e *Error) Unwrap() error {
+	return codeErrors[e.Code]
+}
+
+// newError : the error with the code of the first error of the packages that err wraps
+func newError(err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+
+	for code, target := range codeErrors {
+		if errors.Is(err, target) {
+			return &Error{code, err.Error()}
+		}
+	}
+
+	return &Error{CodeInternalError, err.Error()}
+}
+
+// invalidParams : the params of the request are missing or wrong
+func invalidParams(format string, a ...interface{}) *Error {
+	return &Error{CodeInvalidParams, fmt.Sprintf(format, a...)}
+}
+
+// Server : JSON-RPC endpoint of a node
+type Server struct {
+	Address    string // host:port where the server listens
+	CookieFile string // where the token of the clients is written, the cookie file of the network by default
+	Logger     *log.Logger
+
+	node    *network.Server
+	options blockchain.Options // network of the chain and wallet file used by the send method
+	methods map[string]func(params json.RawMessage) (interface{}, error)
+	token   string // hex encoded, sent by the clients in the Authorization header
+
+	http *http.Server
+	wg   sync.WaitGroup
+}
+
+// NewServer : JSON-RPC server that listens on the address and uses the chain of the node
+func NewServer(address string, node *network.Server, opts blockchain.Options) *Server {
+	s := &Server{
+		Address:    address,
+		CookieFile: opts.CookieFile(),
+		Logger:     log.New(os.Stderr, fmt.Sprintf("[rpc %s] ", address), log.LstdFlags),
+		node:       node,
+		options:    opts,
+	}
+	s.methods = map[string]func(params json.RawMessage) (interface{}, error){
+		"getbalance":       s.getBalance,
+		"getbestblockhash": s.getBestBlockHash,
+		"getblock":         s.getBlock,
+		"getblockbyheight": s.getBlockByHeight,
+		"getchaininfo":     s.getChainInfo,
+		"gethistory":       s.getHistory,
+		"getmempool":       s.getMempool,
+		"gettransaction":   s.getTransaction,
+		"gettxproof":       s.getTxProof,
+		"send":             s.send,
+		"sendtransaction":  s.sendTransaction,
+	}
+
+	return s
+}
+
+// Start : write a new token to the cookie file and listen for requests in the background
+func (s *Server) Start() error {
+	token := make([]byte, tokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+	s.token = hex.EncodeToString(token)
+	if err := ioutil.WriteFile(s.CookieFile, []byte(s.token), 0600); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		os.Remove(s.CookieFile)
+		return err
+	}
+	s.Address = ln.Addr().String() // the real port when a random one was requested
+	s.Logger.SetPrefix(fmt.Sprintf("[rpc %s] ", s.Address))
+
+	s.http = &http.Server{
+		Handler:      s,
+		ReadTimeout:  ioTimeout,
+		WriteTimeout: ioTimeout,
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.http.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.Logger.Printf("Serve: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Close : stop listening, wait until the requests being handled are done and remove the cookie file
+func (s *Server) Close() error {
+	if s.http == nil { // never started
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ioTimeout)
+	defer cancel()
+
+	err := s.http.Shutdown(ctx)
+	s.wg.Wait()
+
+	if rmErr := os.Remove(s.CookieFile); rmErr != nil && err == nil {
+		err = rmErr
+	}
+
+	return err
+}
+
+// ServeHTTP : answer a single request or a batch of requests sent with POST
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "JSON-RPC requests must be sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "the request must have the token of the cookie file", http.StatusUnauthorized)
+		return
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		http.Error(w, "the Content-Type of a JSON-RPC request must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var reply interface{}
+	body = bytes.TrimSpace(body)
+
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			reply = errorResponse(nil, &Error{CodeParseError, err.Error()})
+		} else if len(batch) == 0 {
+			reply = errorResponse(nil, &Error{CodeInvalidRequest, "empty batch"})
+		} else {
+			var responses []*Response
+			for _, raw := range batch {
+				if response := s.handle(raw); response != nil {
+					responses = append(responses, response)
+				}
+			}
+			if len(responses) > 0 { // a batch of notifications gets no response
+				reply = responses
+			}
+		}
+	} else if response := s.handle(body); response != nil {
+		reply = response
+	}
+
+	if reply == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reply); err != nil {
+		s.Logger.Printf("Write: %v", err)
+	}
+}
+
+// authorized : the request has the token of the cookie file
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return s.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// handle : run the method of a single request, returns nil for a notification
+func (s *Server) handle(raw json.RawMessage) *Response {
+	var request Request
+	if err := json.Unmarshal(raw, &request); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return errorResponse(nil, &Error{CodeParseError, err.Error()})
+		}
+		return errorResponse(nil, &Error{CodeInvalidRequest, err.Error()})
+	}
+	if request.Version != version || request.Method == "" {
+		return errorResponse(request.ID, &Error{CodeInvalidRequest, `the request must have "jsonrpc": "2.0" and a method`})
+	}
+
+	method, ok := s.methods[request.Method]
+	if !ok {
+		if request.ID == nil {
+			return nil
+		}
+		return errorResponse(request.ID, &Error{CodeMethodNotFound, fmt.Sprintf("method %q not found", request.Method)})
+	}
+
+	result, err := method(request.Params)
+	if request.ID == nil { // the caller doesn't want an answer
+		return nil
+	}
+	if err != nil {
+		return errorResponse(request.ID, newError(err))
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(request.ID, newError(err))
+	}
+
+	return &Response{Version: version, Result: encoded, ID: request.ID}
+}
+
+// errorResponse : response with the error, the ID is null when the request couldn't be read
+func errorResponse(ID json.RawMessage, err *Error) *Response {
+	if ID == nil {
+		ID = json.RawMessage("null")
+	}
+
+	return &Response{Version: version, Error: err, ID: ID}
+}
+
+// decodeParams : decode the params object of a request into v, unknown fields are rejected
+func decodeParams(params json.RawMessage, v interface{}) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil
+	}
+	if params[0] != '{' {
+		return invalidParams("params must be an object")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return invalidParams("%v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Dieg0Code/Blockchain.go/blockchain"
+)
+
+func TestServerRequiresTokenAndJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewServer("127.0.0.1:0", nil, blockchain.Options{DataDir: dir, Network: "test"})
+	s.CookieFile = filepath.Join(dir, ".cookie")
+	s.Logger = log.New(ioutil.Discard, "", 0)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	token, err := ReadCookie(s.CookieFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name        string
+		token       string
+		contentType string
+		status      int
+	}{
+		{"no token", "", "application/json", http.StatusUnauthorized},
+		{"wrong token", strings.Repeat("0", len(token)), "application/json", http.StatusUnauthorized},
+		{"form", token, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"text", token, "text/plain", http.StatusUnsupportedMediaType},
+		{"json", token, "application/json; charset=utf-8", http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","method":"unknown","id":1}`))
+		r.Header.Set("Content-Type", test.contentType)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadCookie(s.CookieFile); err == nil {
+		t.Error("the cookie file is still there after Close")
+	}
+}
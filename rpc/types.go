@@ -0,0 +1,260 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/Dieg0Code/Blockchain.go/blockchain"
+	"github.com/Dieg0Code/Blockchain.go/wallet"
+)
+
+// Block : JSON view of a block, the hashes are hex encoded
+type Block struct {
+	Hash         string        `json:"hash"`
+	Height       int           `json:"height"`
+	Version      int           `json:"version"`
+	PrevHash     string        `json:"prevHash"`
+	MerkleRoot   string        `json:"merkleRoot"`
+	Timestamp    int64         `json:"timestamp"`
+	Difficulty   int           `json:"difficulty"`
+	Nonce        int           `json:"nonce"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// BlockInfo : a block and whether its header follows the rules of the chain of the node
+type BlockInfo struct {
+	Block
+	ValidHeader bool `json:"validHeader"`
+}
+
+// Transaction : JSON view of a transaction
+type Transaction struct {
+	ID       string   `json:"txid"`
+	Version  int      `json:"version"`
+	Coinbase bool     `json:"coinbase"`
+	Inputs   []Input  `json:"inputs"`
+	Outputs  []Output `json:"outputs"`
+}
+
+// Input : JSON view of an input, the output out of the transaction with the ID that it spends
+type Input struct {
+	ID        string `json:"txid"`
+	Out       int    `json:"out"`
+	Signature string `json:"signature"`
+	PubKey    string `json:"pubKey"`
+}
+
+// Output : JSON view of an output, the address is derived from the public key hash that locks it
+type Output struct {
+	Value      int    `json:"value"`
+	PubKeyHash string `json:"pubKeyHash"`
+	Address    string `json:"address"`
+}
+
+// TransactionInfo : a transaction and where it is, an unconfirmed transaction is in the mempool
+type TransactionInfo struct {
+	Transaction
+	BlockHash     string `json:"blockHash,omitempty"`
+	Height        int    `json:"height"` // -1 while the transaction is unconfirmed
+	Confirmations int    `json:"confirmations"`
+}
+
+// AddressTx : JSON view of a transaction in the history of an address
+type AddressTx struct {
+	TxID           string   `json:"txid"`
+	BlockHash      string   `json:"blockHash"`
+	Height         int      `json:"height"`
+	Position       int      `json:"position"` // position of the transaction inside of its block
+	Timestamp      int64    `json:"timestamp"`
+	Coinbase       bool     `json:"coinbase"`
+	Received       int      `json:"received"`
+	Sent           int      `json:"sent"`
+	Balance        int      `json:"balance"` // balance of the address after the transaction
+	Counterparties []string `json:"counterparties"`
+}
+
+// MempoolEntry : a transaction waiting to be mined and the fee that it pays
+type MempoolEntry struct {
+	Transaction
+	Fee int `json:"fee"`
+}
+
+// Balance : the coins locked to an address
+type Balance struct {
+	Address string `json:"address"`
+	Balance int    `json:"balance"`
+}
+
+// ChainInfo : state of the chain of the node
+type ChainInfo struct {
+	Network       string   `json:"network"`
+	Height        int      `json:"height"`
+	BestBlockHash string   `json:"bestBlockHash"`
+	Difficulty    int      `json:"difficulty"`
+	ChainWork     string   `json:"chainWork"` // hex encoded
+	Supply        int      `json:"supply"`    // coins in circulation
+	MempoolSize   int      `json:"mempoolSize"`
+	Peers         []string `json:"peers"`
+}
+
+// NewBlock : JSON view of the block
+func NewBlock(block *blockchain.Block) Block {
+	view := Block{
+		Hash:         hex.EncodeToString(block.Hash),
+		Height:       block.Height,
+		Version:      block.Version,
+		PrevHash:     hex.EncodeToString(block.PrevHash),
+		MerkleRoot:   hex.EncodeToString(block.MerkleRoot),
+		Timestamp:    block.Timestamp,
+		Difficulty:   block.Difficulty,
+		Nonce:        block.Nonce,
+		Transactions: make([]Transaction, 0, len(block.Transactions)),
+	}
+	for _, tx := range block.Transactions {
+		view.Transactions = append(view.Transactions, NewTransaction(tx))
+	}
+
+	return view
+}
+
+// NewTransaction : JSON view of the transaction
+func NewTransaction(tx *blockchain.Transaction) Transaction {
+	view := Transaction{
+		ID:       hex.EncodeToString(tx.ID),
+		Version:  tx.Version,
+		Coinbase: tx.IsCoinbase(),
+		Inputs:   make([]Input, 0, len(tx.Inputs)),
+		Outputs:  make([]Output, 0, len(tx.Outputs)),
+	}
+	for _, in := range tx.Inputs {
+		view.Inputs = append(view.Inputs, Input{
+			ID:        hex.EncodeToString(in.ID),
+			Out:       in.Out,
+			Signature: hex.EncodeToString(in.Signature),
+			PubKey:    hex.EncodeToString(in.PubKey),
+		})
+	}
+	for _, out := range tx.Outputs {
+		view.Outputs = append(view.Outputs, Output{
+			Value:      out.Value,
+			PubKeyHash: hex.EncodeToString(out.PubKeyHash),
+			Address:    string(wallet.AddressFromPubKeyHash(out.PubKeyHash)),
+		})
+	}
+
+	return view
+}
+
+// NewAddressTx : JSON view of the entry of the history of an address
+func NewAddressTx(entry blockchain.AddressTx) AddressTx {
+	return AddressTx{
+		TxID:           hex.EncodeToString(entry.TxID),
+		BlockHash:      hex.EncodeToString(entry.BlockHash),
+		Height:         entry.Height,
+		Position:       entry.Position,
+		Timestamp:      entry.Timestamp,
+		Coinbase:       entry.Coinbase,
+		Received:       entry.Received,
+		Sent:           entry.Sent,
+		Balance:        entry.Balance,
+		Counterparties: append([]string{}, entry.Counterparties...), // an empty list instead of null
+	}
+}
+
+/*
+	The CLI prints the results of a daemon with the same functions that print the data of the
+	database, so the views can be decoded back into the types of the blockchain package.
+
+	Esp:
+
+	La CLI imprime los resultados de un daemon con las mismas funciones que imprimen los datos de
+	la base de datos, asi las vistas se pueden decodificar de vuelta a los tipos del paquete
+	blockchain.
+*/
+
+// Decode : the block of the view
+func (view Block) Decode() (*blockchain.Block, error) {
+	var err error
+	block := &blockchain.Block{}
+	block.Version = view.Version
+	block.Height = view.Height
+	block.Timestamp = view.Timestamp
+	block.Difficulty = view.Difficulty
+	block.Nonce = view.Nonce
+
+	if block.Hash, err = hex.DecodeString(view.Hash); err != nil {
+		return nil, fmt.Errorf("hash: %w", err)
+	}
+	if block.PrevHash, err = hex.DecodeString(view.PrevHash); err != nil {
+		return nil, fmt.Errorf("prevHash: %w", err)
+	}
+	if block.MerkleRoot, err = hex.DecodeString(view.MerkleRoot); err != nil {
+		return nil, fmt.Errorf("merkleRoot: %w", err)
+	}
+
+	for _, txView := range view.Transactions {
+		tx, err := txView.Decode()
+		if err != nil {
+			return nil, err
+		}
+		block.Transactions = append(block.Transactions, tx)
+	}
+
+	return block, nil
+}
+
+// Decode : the transaction of the view
+func (view Transaction) Decode() (*blockchain.Transaction, error) {
+	ID, err := hex.DecodeString(view.ID)
+	if err != nil {
+		return nil, fmt.Errorf("txid: %w", err)
+	}
+	tx := &blockchain.Transaction{Version: view.Version, ID: ID}
+
+	for _, in := range view.Inputs {
+		input := blockchain.TxInput{Out: in.Out}
+		if input.ID, err = hex.DecodeString(in.ID); err != nil {
+			return nil, fmt.Errorf("input of %s: %w", view.ID, err)
+		}
+		if input.Signature, err = hex.DecodeString(in.Signature); err != nil {
+			return nil, fmt.Errorf("input of %s: %w", view.ID, err)
+		}
+		if input.PubKey, err = hex.DecodeString(in.PubKey); err != nil {
+			return nil, fmt.Errorf("input of %s: %w", view.ID, err)
+		}
+		tx.Inputs = append(tx.Inputs, input)
+	}
+	for _, out := range view.Outputs {
+		pubKeyHash, err := hex.DecodeString(out.PubKeyHash)
+		if err != nil {
+			return nil, fmt.Errorf("output of %s: %w", view.ID, err)
+		}
+		tx.Outputs = append(tx.Outputs, blockchain.TxOutput{Value: out.Value, PubKeyHash: pubKeyHash})
+	}
+
+	return tx, nil
+}
+
+// Decode : the entry of the history of the view
+func (view AddressTx) Decode() (blockchain.AddressTx, error) {
+	entry := blockchain.AddressTx{
+		Height:         view.Height,
+		Position:       view.Position,
+		Timestamp:      view.Timestamp,
+		Coinbase:       view.Coinbase,
+		Received:       view.Received,
+		Sent:           view.Sent,
+		Balance:        view.Balance,
+		Counterparties: view.Counterparties,
+	}
+
+	var err error
+	if entry.TxID, err = hex.DecodeString(view.TxID); err != nil {
+		return entry, fmt.Errorf("txid: %w", err)
+	}
+	if entry.BlockHash, err = hex.DecodeString(view.BlockHash); err != nil {
+		return entry, fmt.Errorf("blockHash: %w", err)
+	}
+
+	return entry, nil
+}
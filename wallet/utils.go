@@ -0,0 +1,21 @@
+package wallet
+
+import (
+	"github.com/mr-tron/base58"
+)
+
+// Base58Encode : encode a slice of bytes into base58
+/*
+	Base58 is the same as base64 without the characters that are easy to confuse:
+	0 (zero), O (capital o), I (capital i), l (lowercase L), + and /
+*/
+func Base58Encode(input []byte) []byte {
+	encode := base58.Encode(input)
+
+	return []byte(encode)
+}
+
+// Base58Decode : decode a base58 slice of bytes
+func Base58Decode(input []byte) ([]byte, error) {
+	return base58.Decode(string(input))
+}
@@ -0,0 +1,160 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// ErrInvalidAddress : the address is not well formed or its checksum doesn't match
+var ErrInvalidAddress = errors.New("invalid address")
+
+const (
+	checksumLength = 4          // bytes of the double sha256 that are appended to the address
+	version        = byte(0x00) // version byte prepended to the public key hash
+	keyLength      = 32         // bytes needed to represent each coordinate of a P-256 point
+)
+
+/*
+	An address is derived from the public key of a wallet:
+
+	1. public key -> sha256 -> ripemd160 = public key hash
+	2. version byte + public key hash -> sha256 -> sha256 -> first 4 bytes = checksum
+	3. base58(version byte + public key hash + checksum) = address
+
+	Esp:
+
+	Una dirección se obtiene a partir de la llave publica de una billetera:
+
+	1. llave publica -> sha256 -> ripemd160 = hash de la llave publica
+	2. byte de versión + hash de la llave publica -> sha256 -> sha256 -> primeros 4 bytes = checksum
+	3. base58(byte de versión + hash de la llave publica + checksum) = dirección
+*/
+
+// Wallet : a private key and the public key derived from it
+type Wallet struct {
+	PrivateKey ecdsa.PrivateKey
+	PublicKey  []byte
+}
+
+// Address : derives the base58 address of the wallet
+func (w Wallet) Address() []byte {
+	return AddressFromPubKeyHash(PublicKeyHash(w.PublicKey))
+}
+
+// AddressFromPubKeyHash : the base58 address of the public key hash, the owner of an output
+func AddressFromPubKeyHash(pubHash []byte) []byte {
+	versionedHash := append([]byte{version}, pubHash...)
+	checksum := Checksum(versionedHash)
+
+	fullHash := append(versionedHash, checksum...)
+	address := Base58Encode(fullHash)
+
+	return address
+}
+
+// ValidateAddress : check that an address is well formed and that its checksum matches
+func ValidateAddress(address string) bool {
+	fullHash, err := Base58Decode([]byte(address))
+	if err != nil || len(fullHash) != 1+ripemd160.Size+checksumLength {
+		return false
+	}
+
+	actualChecksum := fullHash[len(fullHash)-checksumLength:]
+	addressVersion := fullHash[0]
+	pubKeyHash := fullHash[1 : len(fullHash)-checksumLength]
+	targetChecksum := Checksum(append([]byte{addressVersion}, pubKeyHash...))
+
+	return addressVersion == version && bytes.Equal(actualChecksum, targetChecksum)
+}
+
+// NewKeyPair : generates a new P-256 private key and its public key
+func NewKeyPair() (ecdsa.PrivateKey, []byte, error) {
+	curve := elliptic.P256()
+
+	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return ecdsa.PrivateKey{}, nil, err
+	}
+
+	return *private, publicKeyBytes(&private.PublicKey), nil
+}
+
+// MakeWallet : creates a wallet with a brand new key pair
+func MakeWallet() (*Wallet, error) {
+	private, public, err := NewKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	wallet := Wallet{private, public}
+
+	return &wallet, nil
+}
+
+// PublicKeyHash : ripemd160(sha256(public key))
+func PublicKeyHash(pubKey []byte) []byte {
+	pubHash := sha256.Sum256(pubKey)
+
+	hasher := ripemd160.New()
+	hasher.Write(pubHash[:]) // writing to a hash never returns an error
+
+	publicRipMD := hasher.Sum(nil)
+
+	return publicRipMD
+}
+
+// Checksum : first bytes of a double sha256 of the payload
+func Checksum(payload []byte) []byte {
+	firstHash := sha256.Sum256(payload)
+	secondHash := sha256.Sum256(firstHash[:])
+
+	return secondHash[:checksumLength]
+}
+
+// GobEncode : the elliptic curve can't be encoded by gob, so the private key is stored as DER
+func (w Wallet) GobEncode() ([]byte, error) {
+	return x509.MarshalECPrivateKey(&w.PrivateKey)
+}
+
+// GobDecode : rebuilds the key pair from the DER encoded private key
+func (w *Wallet) GobDecode(data []byte) error {
+	private, err := x509.ParseECPrivateKey(data)
+	if err != nil {
+		return err
+	}
+
+	w.PrivateKey = *private
+	w.PublicKey = publicKeyBytes(&private.PublicKey)
+
+	return nil
+}
+
+// the public key is the X and Y coordinates of the point, each one padded to the same length
+func publicKeyBytes(pub *ecdsa.PublicKey) []byte {
+	pubKey := make([]byte, 2*keyLength)
+	pub.X.FillBytes(pubKey[:keyLength])
+	pub.Y.FillBytes(pubKey[keyLength:])
+
+	return pubKey
+}
+
+// PubKeyHashFromAddress : extract the public key hash from a base58 address
+func PubKeyHashFromAddress(address string) ([]byte, error) {
+	if !ValidateAddress(address) {
+		return nil, fmt.Errorf("%w %q", ErrInvalidAddress, address)
+	}
+
+	fullHash, err := Base58Decode([]byte(address))
+	if err != nil {
+		return nil, err
+	}
+
+	return fullHash[1 : len(fullHash)-checksumLength], nil
+}
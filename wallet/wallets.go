@@ -0,0 +1,112 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Wallets : all the wallets stored in the wallet file indexed by address
+type Wallets struct {
+	Wallets map[string]*Wallet
+	file    string // path of the wallet file
+}
+
+// ErrWalletNotFound : the wallet file doesn't have the keys of the address
+var ErrWalletNotFound = errors.New("wallet not found")
+
+// CreateWallets : load the wallets stored in the wallet file, a missing file is an empty set of wallets
+func CreateWallets(walletFile string) (*Wallets, error) {
+	wallets := Wallets{}
+	wallets.Wallets = make(map[string]*Wallet)
+	wallets.file = walletFile
+
+	err := wallets.LoadFile()
+	if os.IsNotExist(err) {
+		err = nil
+	}
+
+	return &wallets, err
+}
+
+// AddWallet : creates a new wallet and returns its address
+func (ws *Wallets) AddWallet() (string, error) {
+	wallet, err := MakeWallet()
+	if err != nil {
+		return "", err
+	}
+	address := string(wallet.Address())
+
+	ws.Wallets[address] = wallet
+
+	return address, nil
+}
+
+// GetAllAddresses : addresses of all the wallets
+func (ws *Wallets) GetAllAddresses() []string {
+	var addresses []string
+
+	for address := range ws.Wallets {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	return addresses
+}
+
+// GetWallet : wallet that owns the address
+func (ws Wallets) GetWallet(address string) (Wallet, error) {
+	w, ok := ws.Wallets[address]
+	if !ok {
+		return Wallet{}, fmt.Errorf("%w: %s", ErrWalletNotFound, address)
+	}
+
+	return *w, nil
+}
+
+// LoadFile : read the wallets from the wallet file
+func (ws *Wallets) LoadFile() error {
+	if _, err := os.Stat(ws.file); os.IsNotExist(err) {
+		return err
+	}
+
+	var wallets Wallets
+
+	fileContent, err := ioutil.ReadFile(ws.file)
+	if err != nil {
+		return err
+	}
+
+	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
+	err = decoder.Decode(&wallets)
+	if err != nil {
+		return err
+	}
+
+	ws.Wallets = wallets.Wallets
+
+	return nil
+}
+
+// SaveFile : write the wallets into the wallet file
+func (ws *Wallets) SaveFile() error {
+	var content bytes.Buffer
+
+	encoder := gob.NewEncoder(&content)
+	err := encoder.Encode(ws)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(ws.file), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(ws.file, content.Bytes(), 0600) // the file holds private keys, only the owner can read it
+}